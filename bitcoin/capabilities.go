@@ -0,0 +1,213 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"forklol-collector/rpc"
+	"log"
+	"strings"
+)
+
+// Node implementations, as detected from the user agent
+const (
+	ImplCore     = "core"
+	ImplKnots    = "knots"
+	ImplABC      = "abc"
+	ImplBCHN     = "bchn"
+	ImplElements = "elements"
+	ImplUnknown  = "unknown"
+)
+
+// Capabilities describe what a node can do, as found by ProbeCapabilities
+type Capabilities struct {
+	Implementation string
+	Version        int
+	SubVersion     string
+
+	// BlockStats is true when getblockstats works, BlockStatsRange when it takes a range of heights like the patched
+	// fork.lol nodes do
+	BlockStats      bool
+	BlockStatsRange bool
+	// SegWitHeight is the height segwit activated at, -1 when it is not active on the chain
+	SegWitHeight int64
+}
+
+// SegWitAt returns true when segwit is active at the given height
+func (c *Capabilities) SegWitAt(height uint64) bool {
+	return c.SegWitHeight >= 0 && height >= uint64(c.SegWitHeight)
+}
+
+func (c *Capabilities) String() string {
+	return fmt.Sprintf("%s %d (%s), getblockstats: %t (range: %t), segwit height: %d", c.Implementation, c.Version, c.SubVersion, c.BlockStats, c.BlockStatsRange, c.SegWitHeight)
+}
+
+// ProbeCapabilities asks the node for its version and soft forks and tries getblockstats on its tip
+func ProbeCapabilities(ctx context.Context, client *rpc.Client) (*Capabilities, error) {
+	network, err := client.GetNetworkInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := client.GetBlockchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := Capabilities{
+		Implementation: implementation(network.SubVersion),
+		Version:        network.Version,
+		SubVersion:     network.SubVersion,
+		SegWitHeight:   -1,
+	}
+
+	if caps.SegWitHeight, err = segwitHeight(ctx, client, chain); err != nil {
+		return nil, err
+	}
+
+	if caps.BlockStats, caps.BlockStatsRange, err = probeBlockStats(ctx, client, chain.Height); err != nil {
+		return nil, err
+	}
+
+	return &caps, nil
+}
+
+// probeBlockStats tries the range form of getblockstats of the patched fork.lol nodes first, which stock nodes reject
+// (with ErrCodeType), and then the single height form of stock nodes. Only a missing method means getblockstats is
+// unsupported, any other error is not an answer yet.
+func probeBlockStats(ctx context.Context, client *rpc.Client, height uint64) (bool, bool, error) {
+	_, err := client.GetBlockStats(ctx, height)
+	switch {
+	case err == nil:
+		return true, true, nil
+	case rpc.IsMethodNotFound(err):
+		return false, false, nil
+	case rpc.IsTransportError(err), rpc.IsWarmingUp(err):
+		return false, false, err
+	}
+
+	_, err = client.GetStockBlockStats(ctx, height)
+	switch {
+	case err == nil:
+		return true, false, nil
+	case rpc.IsMethodNotFound(err):
+		return false, false, nil
+	}
+
+	return false, false, err
+}
+
+// implementation detects the node implementation from its user agent, e.g. /Satoshi:0.21.0/
+func implementation(subversion string) string {
+	switch {
+	case strings.Contains(subversion, "Knots"):
+		return ImplKnots
+	case strings.Contains(subversion, "Bitcoin ABC"):
+		return ImplABC
+	case strings.Contains(subversion, "Bitcoin Cash Node"):
+		return ImplBCHN
+	case strings.Contains(subversion, "Elements"):
+		return ImplElements
+	case strings.Contains(subversion, "Satoshi"):
+		return ImplCore
+	}
+
+	return ImplUnknown
+}
+
+// segwitHeight finds the segwit activation height in whatever format the node reports its soft forks
+func segwitHeight(ctx context.Context, client *rpc.Client, chain *rpc.BlockchainInfo) (int64, error) {
+	// Core 0.14 - 0.18
+	if dep, ok := chain.BIP9SoftForks["segwit"]; ok {
+		if dep.Status == "active" {
+			return dep.Since, nil
+		}
+		return -1, nil
+	}
+
+	// Core 0.19 - 22
+	forks := map[string]rpc.Deployment{}
+	if len(chain.SoftForks) > 0 && json.Unmarshal(chain.SoftForks, &forks) == nil {
+		if dep, ok := forks["segwit"]; ok {
+			return activeHeight(dep), nil
+		}
+	}
+
+	// Core 23+ moved soft forks to getdeploymentinfo, nodes without segwit do not have it or do not list segwit
+	if chain.SoftForks == nil {
+		deployments, err := client.GetDeploymentInfo(ctx)
+		if rpc.IsMethodNotFound(err) {
+			return -1, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		if dep, ok := deployments["segwit"]; ok {
+			return activeHeight(dep), nil
+		}
+	}
+
+	return -1, nil
+}
+
+func activeHeight(dep rpc.Deployment) int64 {
+	if !dep.Active {
+		return -1
+	}
+	return dep.Height
+}
+
+// loadCapabilities probes the node when that was not done yet. The caller must hold TxLock.
+func (c *ChainSync) loadCapabilities(ctx context.Context) error {
+	if c.caps != nil {
+		return nil
+	}
+
+	caps, err := ProbeCapabilities(ctx, c.Coin.RPCClient())
+	if err != nil {
+		return err
+	}
+
+	p := c.Coin.Params
+	log.Printf("%s node: %s\n", c.Coin.Symbol, caps)
+	log.Printf("%s chain: %ds blocks, %s difficulty retargeting every %d blocks, %s proof of work, subsidy halving every %d blocks\n",
+		c.Coin.Symbol, p.TargetSpacing, p.DifficultyAlgorithm, p.RetargetInterval, p.HashAlgorithm, p.HalvingInterval)
+	c.caps = caps
+
+	return nil
+}
+
+// useBlockStats returns true when block stats should come from getblockstats instead of being computed from the
+// block: the rpc_stats option when it is set, otherwise whether the node supports getblockstats. The caller must
+// hold TxLock.
+func (c *ChainSync) useBlockStats() bool {
+	if c.Coin.RPCStats != nil {
+		return *c.Coin.RPCStats
+	}
+
+	return c.caps != nil && c.caps.BlockStats
+}
+
+// useRangeStats returns true when getblockstats has to be called with a range of heights. The caller must hold TxLock.
+func (c *ChainSync) useRangeStats() bool {
+	return c.caps != nil && c.caps.BlockStatsRange
+}
+
+// statsMapper returns the mapper for the stats of the node. The caller must hold TxLock.
+func (c *ChainSync) statsMapper() StatsMapper {
+	if c.caps == nil {
+		return MapperFor(ImplUnknown)
+	}
+
+	return MapperFor(c.caps.Implementation)
+}
+
+// segwitAt returns true when the block at height should be stored with segwit details: the segwit option when it
+// is set, otherwise whether segwit was active on the chain at that height. The caller must hold TxLock.
+func (c *ChainSync) segwitAt(height uint64) bool {
+	if c.Coin.SegWit != nil {
+		return *c.Coin.SegWit
+	}
+
+	return c.caps != nil && c.caps.SegWitAt(height)
+}
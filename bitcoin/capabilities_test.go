@@ -0,0 +1,129 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"forklol-collector/db"
+	"testing"
+)
+
+func infoMethods(subversion string, stats nodeMethod) map[string]nodeMethod {
+	methods := map[string]nodeMethod{
+		"getnetworkinfo": func([]json.RawMessage) (interface{}, int) {
+			return map[string]interface{}{"version": 210000, "subversion": subversion}, 0
+		},
+		"getblockchaininfo": func([]json.RawMessage) (interface{}, int) {
+			return map[string]interface{}{"chain": "main", "blocks": 500000, "softforks": map[string]interface{}{
+				"segwit": map[string]interface{}{"type": "buried", "active": true, "height": 481824},
+			}}, 0
+		},
+	}
+
+	if stats != nil {
+		methods["getblockstats"] = stats
+	}
+
+	return methods
+}
+
+func TestProbeBlockStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		subversion string
+		stats      nodeMethod
+		blockStats bool
+		rangeStats bool
+		impl       string
+	}{
+		{"stock core", "/Satoshi:0.21.0/", stockBlockStats(coreStockStats), true, false, ImplCore},
+		{"patched node", "/Satoshi:0.15.0/", rangeBlockStats(coreStockStats), true, true, ImplCore},
+		{"stock bchn", "/Bitcoin Cash Node:23.0.0(EB32.0)/", stockBlockStats(bchStockStats), true, false, ImplBCHN},
+		{"no getblockstats", "/Satoshi:0.14.0/", nil, false, false, ImplCore},
+	}
+
+	for _, test := range tests {
+		node := fakeNode(infoMethods(test.subversion, test.stats))
+		c := testSyncer(t, node.URL)
+
+		caps, err := ProbeCapabilities(context.Background(), c.Coin.RPCClient())
+		node.Close()
+
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if caps.BlockStats != test.blockStats || caps.BlockStatsRange != test.rangeStats || caps.Implementation != test.impl {
+			t.Errorf("%s: probed %s", test.name, caps)
+		}
+	}
+}
+
+// a stock node has to get its stats fetched the way it was probed, one height and scalar values
+func TestCollectStockBlockStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		subversion string
+		stats      map[string]interface{}
+		field      func(*db.Details) *int64
+		expected   interface{}
+	}{
+		{"core", "/Satoshi:0.21.0/", coreStockStats, func(d *db.Details) *int64 { return d.AvgFeeRate }, int64(60)},
+		{"core percentiles", "/Satoshi:0.21.0/", coreStockStats, func(d *db.Details) *int64 { return d.FeeRateP90 }, int64(150)},
+		{"bchn", "/Bitcoin Cash Node:23.0.0(EB32.0)/", bchStockStats, func(d *db.Details) *int64 { return d.VAvgFeeRate }, int64(2)},
+	}
+
+	for _, test := range tests {
+		node := fakeNode(infoMethods(test.subversion, stockBlockStats(test.stats)))
+		c := testSyncer(t, node.URL)
+
+		if err := c.loadCapabilities(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := c.collectBlockStats(context.Background(), 500000)
+		node.Close()
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		d := c.statsMapper().Map("TEST", 500000, *stats, c.segwitAt(500000))
+		if v := value(test.field(d)); v != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.name, v, test.expected)
+		}
+	}
+}
+
+func TestFetchStockBlockStats(t *testing.T) {
+	methods := infoMethods("/Satoshi:0.21.0/", stockBlockStats(coreStockStats))
+	methods["getblockhash"] = func(params []json.RawMessage) (interface{}, int) {
+		return "hash" + string(params[0]), 0
+	}
+	methods["getblockheader"] = func(params []json.RawMessage) (interface{}, int) {
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		return map[string]interface{}{"hash": hash}, 0
+	}
+
+	node := fakeNode(methods)
+	defer node.Close()
+
+	c := testSyncer(t, node.URL)
+
+	batch := c.fetchBlocks(context.Background(), 10, 12, fetchBlockStats, c.Coin.Params)
+	if len(batch.blocks) != 3 || batch.blocks[0].err != nil {
+		t.Fatalf("fetched %+v", batch.blocks[0])
+	}
+
+	for _, fetched := range batch.blocks {
+		if fee := (*fetched.stats)["totalfee"]; fee != 5e7 {
+			t.Errorf("block %d has a total fee of %v", fetched.height, fee)
+		}
+	}
+
+	// the range form fails on a stock node
+	if batch := c.fetchBlocks(context.Background(), 10, 12, fetchRangeStats, c.Coin.Params); batch.blocks[0].err == nil {
+		t.Error("expected the range form of getblockstats to fail")
+	}
+}
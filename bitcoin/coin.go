@@ -1,22 +1,98 @@
 package bitcoin
 
-import "forklol-collector/rpc"
+import (
+	"fmt"
+	"forklol-collector/config"
+	"forklol-collector/rpc"
+	"time"
+)
 
 type Coin struct {
-	Symbol   string
-	RPCUrl   string
-	RPCUser  string
-	RPCPass  string
-	RPCStats bool
-	SegWit   bool
+	Symbol    string
+	Endpoints []rpc.Endpoint
+	RPCStats  *bool
+	SegWit    *bool
+	ZMQUrl    string
+
+	PollInterval time.Duration
+	FetchWorkers int
+	HeaderSync   bool
+	StartHeight  uint64
+	Params       config.ChainParams
+	RPCTimeout   time.Duration
+	RPCRetries   int
 
 	rpc *rpc.Client
 }
 
-func (c Coin) RPCClient() *rpc.Client {
-	if c.rpc == nil {
-		client := rpc.NewClient(c.RPCUrl, c.RPCUser, c.RPCPass)
-		c.rpc = &client
+// NewCoin creates a Coin from the options found in the config file
+func NewCoin(opts config.CoinOptions) (Coin, error) {
+	coin := Coin{
+		Symbol:   opts.Symbol,
+		RPCStats: opts.RPCStats,
+		SegWit:   opts.SegWit,
+		ZMQUrl:   opts.ZMQUrl,
+
+		PollInterval: time.Duration(opts.PollInterval) * time.Second,
+		FetchWorkers: opts.FetchWorkers,
+		HeaderSync:   opts.HeaderSync,
+		RPCTimeout:   time.Duration(opts.RPCTimeout) * time.Second,
+	}
+
+	if opts.RPCRetries != nil {
+		coin.RPCRetries = *opts.RPCRetries
+	}
+
+	// options that did not go through Configure have no params, a zero spacing would divide by zero in the hashrates
+	coin.Params = config.BITCOIN_CHAIN_PARAMS
+	if opts.ChainParams != nil && opts.ChainParams.TargetSpacing > 0 {
+		coin.Params = *opts.ChainParams
+	}
+
+	if opts.StartHeight != nil {
+		coin.StartHeight = *opts.StartHeight
+	}
+
+	for _, ep := range opts.Endpoints {
+		endpoint := rpc.Endpoint{
+			URL:        ep.URL,
+			User:       ep.User,
+			Pass:       ep.Pass,
+			CookieFile: ep.CookieFile,
+			Priority:   ep.Priority,
+		}
+
+		if ep.UsesTLS() {
+			endpoint.TLS = &rpc.TLSOptions{
+				CAFile:     ep.TLSCA,
+				CertFile:   ep.TLSCert,
+				KeyFile:    ep.TLSKey,
+				ServerName: ep.TLSServerName,
+			}
+		}
+
+		coin.Endpoints = append(coin.Endpoints, endpoint)
+	}
+
+	client, err := rpc.NewClient(coin.Endpoints, coin.rpcOptions())
+	if err != nil {
+		return Coin{}, fmt.Errorf("%s: %s", coin.Symbol, err.Error())
 	}
+	coin.rpc = client
+
+	return coin, nil
+}
+
+// RPCClient returns the client for the endpoints of the coin. The coin has to be created with NewCoin.
+func (c Coin) RPCClient() *rpc.Client {
 	return c.rpc
 }
+
+func (c Coin) rpcOptions() rpc.Options {
+	return rpc.Options{
+		Timeout:    c.RPCTimeout,
+		Retries:    c.RPCRetries,
+		RetryDelay: rpc.DefaultOptions.RetryDelay,
+		MaxLag:     rpc.DefaultOptions.MaxLag,
+	}
+}
@@ -0,0 +1,114 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"forklol-collector/config"
+	"math/big"
+)
+
+// ForkPoint is the last block two chains have in common
+type ForkPoint struct {
+	Height    uint64
+	Hash      string
+	Time      uint64
+	ChainWork *big.Int
+}
+
+// Fork returns the fork point as an entry of the fork registry
+func (f *ForkPoint) Fork(parent, child string) config.Fork {
+	return config.Fork{
+		Parent:    parent,
+		Child:     child,
+		Height:    f.Height,
+		Time:      f.Time,
+		ChainWork: f.ChainWork.String(),
+		Work:      workFromChainWork(f.ChainWork),
+	}
+}
+
+// FindForkPoint binary searches the block hashes of two coins for the last block they have in common. Both nodes
+// have to agree on the genesis block, and the chains must have diverged: an error is returned when one chain is a
+// prefix of the other.
+func FindForkPoint(ctx context.Context, parent, child Coin) (*ForkPoint, error) {
+	pc, cc := parent.RPCClient(), child.RPCClient()
+
+	parentHeight, _, err := pc.GetLastBlock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", parent.Symbol, err.Error())
+	}
+
+	childHeight, _, err := cc.GetLastBlock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", child.Symbol, err.Error())
+	}
+
+	same := func(h uint64) (bool, error) {
+		ph, err := pc.GetBlockHash(ctx, h)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", parent.Symbol, err.Error())
+		}
+
+		ch, err := cc.GetBlockHash(ctx, h)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", child.Symbol, err.Error())
+		}
+
+		return ph == ch, nil
+	}
+
+	if ok, err := same(0); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("%s and %s do not share a genesis block", parent.Symbol, child.Symbol)
+	}
+
+	// the chains share every block up to the fork point and none after it
+	low, high := uint64(0), parentHeight
+	if childHeight < high {
+		high = childHeight
+	}
+	shorter := high
+
+	for low < high {
+		mid := low + (high-low+1)/2
+
+		ok, err := same(mid)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	// when the tip of the shorter chain is shared one chain extends the other, they did not fork (yet)
+	if low == shorter {
+		return nil, fmt.Errorf("%s and %s have not forked, they share all blocks up to height %d", parent.Symbol, child.Symbol, low)
+	}
+
+	hash, err := pc.GetBlockHash(ctx, low)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", parent.Symbol, err.Error())
+	}
+
+	header, err := pc.GetBlockHeader(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", parent.Symbol, err.Error())
+	}
+
+	chainwork, err := parseChainWork(header.ChainWork)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ForkPoint{
+		Height:    header.Height,
+		Hash:      header.Hash,
+		Time:      header.Time,
+		ChainWork: chainwork,
+	}, nil
+}
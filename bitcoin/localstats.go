@@ -0,0 +1,298 @@
+package bitcoin
+
+import (
+	"context"
+	"forklol-collector/config"
+	"forklol-collector/rpc"
+	"log"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	// what a stored utxo costs besides its serialized output: outpoint, height/coinbase flag
+	utxoOverhead = 41
+	witnessScale = 4
+)
+
+// getTxBlocks gets the blocks with the given hashes including their transactions to compute stats from. Verbosity 3
+// adds the prevouts of the inputs, nodes that reject it (-8) are asked for verbosity 2 from then on.
+func (c *ChainSync) getTxBlocks(ctx context.Context, hashes []string) ([]*rpc.VerboseBlock, error) {
+	client := c.Coin.RPCClient()
+
+	if atomic.LoadInt32(&c.noPrevouts) == 0 {
+		blocks, err := client.GetBlocks(ctx, hashes, 3)
+		if !rpc.IsInvalidParameter(err) {
+			return blocks, err
+		}
+
+		log.Printf("%s node does not support getblock verbosity 3, fees are only known when it returns them.\n", c.Coin.Symbol)
+		atomic.StoreInt32(&c.noPrevouts, 1)
+	}
+
+	return client.GetBlocks(ctx, hashes, 2)
+}
+
+// computeStats derives the fields of getblockstats from a block with its transactions, for nodes that do not
+// implement getblockstats. Per transaction fees are only known when the node returns them (Core 0.21+) or the
+// prevouts of the inputs (verbosity 3), otherwise the fee fields that can not be derived from the coinbase are nil.
+func computeStats(coin string, params config.ChainParams, block *rpc.VerboseBlock) *map[string]interface{} {
+	var (
+		ins, outs, utxos                int64
+		totalOut, reward, utxoSize      int64
+		totalSize, totalWeight          int64
+		swTxs, swTotalSize, swTotalWght int64
+
+		fees, feerates, feeratesOld, sizes, weights []int64
+	)
+
+	feesKnown, utxoSizeKnown := true, true
+
+	for n, tx := range block.Tx {
+		outs += int64(len(tx.Vout))
+
+		txOut := int64(0)
+		for _, out := range tx.Vout {
+			if out.Value != nil {
+				txOut += satoshis(*out.Value)
+			}
+
+			if out.ScriptPubKey.Type == "nulldata" {
+				continue
+			}
+			utxos++
+			utxoSize += outputSize(out) + utxoOverhead
+		}
+
+		if n == 0 {
+			reward = txOut
+			continue
+		}
+
+		ins += int64(len(tx.Vin))
+		totalOut += txOut
+		totalSize += int64(tx.Size)
+		totalWeight += int64(tx.Weight)
+		sizes = append(sizes, int64(tx.Size))
+
+		// the witness hash differs from the txid for segwit transactions
+		if tx.Hash != "" && tx.Hash != tx.Txid {
+			swTxs++
+			swTotalSize += int64(tx.Size)
+			swTotalWght += int64(tx.Weight)
+		}
+
+		txIn, prevoutsKnown := int64(0), true
+		for _, in := range tx.Vin {
+			if in.Prevout == nil || in.Prevout.Value == nil {
+				prevoutsKnown = false
+				continue
+			}
+			txIn += satoshis(*in.Prevout.Value)
+			utxoSize -= outputSize(*in.Prevout) + utxoOverhead
+		}
+
+		if !prevoutsKnown {
+			utxoSizeKnown = false
+		}
+
+		var fee int64
+		switch {
+		case tx.Fee != nil:
+			fee = satoshis(*tx.Fee)
+		case prevoutsKnown:
+			fee = txIn - txOut
+		default:
+			feesKnown = false
+			continue
+		}
+
+		fees = append(fees, fee)
+		if tx.Weight > 0 {
+			feerates = append(feerates, fee*witnessScale/int64(tx.Weight))
+			weights = append(weights, int64(tx.Weight))
+		}
+		if tx.Size > 0 {
+			feeratesOld = append(feeratesOld, fee/int64(tx.Size))
+		}
+	}
+
+	subsidy := params.Subsidy(block.Height)
+	txs := int64(len(block.Tx))
+
+	// the coinbase claims the subsidy and all fees
+	totalFee := reward - subsidy
+	if feesKnown {
+		totalFee = sum(fees)
+	} else if totalFee < 0 {
+		totalFee = 0
+	}
+
+	stats := map[string]interface{}{
+		"coin":           coin,
+		"height":         block.Height,
+		"blockhash":      block.Hash,
+		"time":           block.Time,
+		"mediantime":     block.MedianTime,
+		"txs":            txs,
+		"ins":            ins,
+		"outs":           outs,
+		"subsidy":        subsidy,
+		"reward":         reward,
+		"totalfee":       totalFee,
+		"total_out":      totalOut,
+		"total_size":     totalSize,
+		"total_weight":   totalWeight,
+		"swtxs":          swTxs,
+		"swtotal_size":   swTotalSize,
+		"swtotal_weight": swTotalWght,
+		"utxo_increase":  utxos - ins,
+		"utxo_size_inc":  nil,
+		"avgfee":         divide(totalFee, txs-1),
+		"avgfeerate":     divide(totalFee*witnessScale, totalWeight),
+		"avgfeerate_old": divide(totalFee, totalSize),
+		"avgtxsize":      divide(totalSize, txs-1),
+		"mintxsize":      minimum(sizes),
+		"maxtxsize":      maximum(sizes),
+		"mediantxsize":   median(sizes),
+	}
+
+	if utxoSizeKnown {
+		stats["utxo_size_inc"] = utxoSize
+	}
+
+	if feesKnown {
+		stats["feerate_percentiles"] = percentilesByWeight(feerates, weights)
+	}
+
+	perTx := map[string][]int64{
+		"fee":         fees,
+		"feerate":     feerates,
+		"feerate_old": feeratesOld,
+	}
+
+	for name, vals := range perTx {
+		if !feesKnown {
+			stats["min"+name], stats["max"+name], stats["median"+name] = nil, nil, nil
+			continue
+		}
+
+		stats["min"+name], stats["max"+name], stats["median"+name] = minimum(vals), maximum(vals), median(vals)
+	}
+
+	return &stats
+}
+
+// satoshis converts an rpc amount in BTC to satoshis
+func satoshis(btc float64) int64 {
+	return int64(math.Round(btc * 1e8))
+}
+
+// outputSize returns the serialized size of an output: value, script length and script
+func outputSize(out rpc.TxOut) int64 {
+	script := int64(len(out.ScriptPubKey.Hex) / 2)
+
+	switch {
+	case script < 0xfd:
+		return 8 + 1 + script
+	case script <= 0xffff:
+		return 8 + 3 + script
+	}
+
+	return 8 + 5 + script
+}
+
+func sum(vals []int64) int64 {
+	total := int64(0)
+	for _, v := range vals {
+		total += v
+	}
+
+	return total
+}
+
+func divide(a, b int64) int64 {
+	if b <= 0 {
+		return 0
+	}
+
+	return a / b
+}
+
+func minimum(vals []int64) int64 {
+	if len(vals) == 0 {
+		return 0
+	}
+
+	min := vals[0]
+	for _, v := range vals[1:] {
+		if v < min {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func maximum(vals []int64) int64 {
+	max := int64(0)
+	for _, v := range vals {
+		if v > max {
+			max = v
+		}
+	}
+
+	return max
+}
+
+// percentilesByWeight returns the 10th, 25th, 50th, 75th and 90th percentile feerate, weighted by the weight of the
+// transactions like getblockstats does
+func percentilesByWeight(feerates, weights []int64) []interface{} {
+	percentiles := []interface{}{int64(0), int64(0), int64(0), int64(0), int64(0)}
+	if len(feerates) == 0 {
+		return percentiles
+	}
+
+	order := make([]int, len(feerates))
+	for n := range order {
+		order[n] = n
+	}
+	sort.Slice(order, func(i, j int) bool { return feerates[order[i]] < feerates[order[j]] })
+
+	total := sum(weights)
+	thresholds := []float64{0.1, 0.25, 0.5, 0.75, 0.9}
+
+	next, cumulative := 0, int64(0)
+	for _, n := range order {
+		cumulative += weights[n]
+		for next < len(thresholds) && float64(cumulative) >= float64(total)*thresholds[next] {
+			percentiles[next] = feerates[n]
+			next++
+		}
+	}
+
+	// rounding can leave the last thresholds unreached
+	for ; next < len(thresholds); next++ {
+		percentiles[next] = feerates[order[len(order)-1]]
+	}
+
+	return percentiles
+}
+
+// median returns the truncated median like getblockstats does
+func median(vals []int64) int64 {
+	if len(vals) == 0 {
+		return 0
+	}
+
+	sorted := append([]int64{}, vals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	n := len(sorted)
+	if n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	return sorted[n/2]
+}
@@ -0,0 +1,134 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"forklol-collector/config"
+	"forklol-collector/rpc"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPercentilesByWeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		feerates []int64
+		weights  []int64
+		expected []int64
+	}{
+		{"empty", nil, nil, []int64{0, 0, 0, 0, 0}},
+		{"single", []int64{7}, []int64{400}, []int64{7, 7, 7, 7, 7}},
+		{"equal weights", []int64{5, 1, 4, 2, 3, 6, 8, 7, 10, 9}, []int64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []int64{1, 3, 5, 8, 9}},
+		{"heavy cheap tx", []int64{25, 50}, []int64{800, 400}, []int64{25, 25, 25, 50, 50}},
+		{"heavy expensive tx", []int64{1, 100}, []int64{100, 900}, []int64{1, 100, 100, 100, 100}},
+	}
+
+	for _, test := range tests {
+		percentiles := percentilesByWeight(test.feerates, test.weights)
+
+		got := make([]int64, len(percentiles))
+		for n, p := range percentiles {
+			got[n] = p.(int64)
+		}
+
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: percentiles are %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		vals     []int64
+		expected int64
+	}{
+		{nil, 0},
+		{[]int64{3}, 3},
+		{[]int64{3, 1, 2}, 2},
+		{[]int64{4, 1, 2, 3}, 2},
+		{[]int64{1, 4}, 2},
+	}
+
+	for _, test := range tests {
+		if m := median(test.vals); m != test.expected {
+			t.Errorf("median of %v is %d, expected %d", test.vals, m, test.expected)
+		}
+	}
+}
+
+// a block at height 420000 with a coinbase, a legacy and a segwit transaction. Both pay a fee of 5000 satoshis.
+const statsBlock = `{
+	"height": 420000, "hash": "blockhash", "time": 1468082773, "mediantime": 1468080000,
+	"tx": [
+		{"txid": "cb", "hash": "cb", "size": 100, "weight": 400,
+			"vin": [{"coinbase": "03a06806"}], "vout": [{"value": 12.50010000, "scriptPubKey": {"hex": "SCRIPT", "type": "witness_v0_keyhash"}}]},
+		{"txid": "legacy", "hash": "legacy", "size": 200, "weight": 800 FEE1,
+			"vin": [{PREVOUT1}], "vout": [{"value": 0.99995, "scriptPubKey": {"hex": "SCRIPT", "type": "witness_v0_keyhash"}}]},
+		{"txid": "segwit", "hash": "wtxid", "size": 150, "weight": 400 FEE2,
+			"vin": [{PREVOUT2}], "vout": [{"value": 0.49995, "scriptPubKey": {"hex": "SCRIPT", "type": "witness_v0_keyhash"}},
+				{"value": 0, "scriptPubKey": {"hex": "6a00", "type": "nulldata"}}]}
+	]
+}`
+
+func decodeStatsBlock(t *testing.T, prevouts, fees bool) *rpc.VerboseBlock {
+	script := "0014" + strings.Repeat("ab", 20)
+	prevout := `"prevout": {"value": %s, "scriptPubKey": {"hex": "` + script + `", "type": "witness_v0_keyhash"}}`
+
+	r := strings.NewReplacer("SCRIPT", script, "PREVOUT1", "", "PREVOUT2", "", "FEE1", "", "FEE2", "")
+	if prevouts && fees {
+		t.Fatal("either prevouts or fees")
+	} else if prevouts {
+		r = strings.NewReplacer("SCRIPT", script, "FEE1", "", "FEE2", "",
+			"PREVOUT1", strings.Replace(prevout, "%s", "1.0", 1), "PREVOUT2", strings.Replace(prevout, "%s", "0.5", 1))
+	} else if fees {
+		r = strings.NewReplacer("SCRIPT", script, "PREVOUT1", "", "PREVOUT2", "",
+			"FEE1", `, "fee": 0.00005`, "FEE2", `, "fee": 0.00005`)
+	}
+
+	var block rpc.VerboseBlock
+	if err := json.Unmarshal([]byte(r.Replace(statsBlock)), &block); err != nil {
+		t.Fatal(err)
+	}
+
+	return &block
+}
+
+func TestComputeStats(t *testing.T) {
+	// the fields that are the same however the fees are known
+	common := map[string]interface{}{
+		"height": uint64(420000), "txs": int64(3), "ins": int64(2), "outs": int64(4), "subsidy": int64(1250000000),
+		"reward": int64(1250010000), "totalfee": int64(10000), "total_size": int64(350), "total_weight": int64(1200),
+		"swtxs": int64(1), "swtotal_size": int64(150), "swtotal_weight": int64(400), "utxo_increase": int64(1),
+		"avgfee": int64(5000), "avgfeerate": int64(33), "avgfeerate_old": int64(28),
+	}
+
+	tests := []struct {
+		name     string
+		prevouts bool
+		fees     bool
+		expected map[string]interface{}
+	}{
+		{"verbosity 3", true, false, map[string]interface{}{
+			"minfeerate": int64(25), "maxfeerate": int64(50), "medianfee": int64(5000), "maxfeerate_old": int64(33),
+			"utxo_size_inc": int64(72), "feerate_percentiles": []interface{}{int64(25), int64(25), int64(25), int64(50), int64(50)},
+		}},
+		{"fees of core 0.21", false, true, map[string]interface{}{
+			"minfeerate": int64(25), "maxfeerate": int64(50), "minfee": int64(5000), "utxo_size_inc": nil,
+		}},
+		{"fees unknown", false, false, map[string]interface{}{
+			"minfeerate": nil, "medianfee": nil, "utxo_size_inc": nil, "feerate_percentiles": nil,
+		}},
+	}
+
+	for _, test := range tests {
+		stats := *computeStats("BTC", config.BITCOIN_CHAIN_PARAMS, decodeStatsBlock(t, test.prevouts, test.fees))
+
+		for _, expected := range []map[string]interface{}{common, test.expected} {
+			for key, value := range expected {
+				if !reflect.DeepEqual(stats[key], value) {
+					t.Errorf("%s: %s is %#v, expected %#v", test.name, key, stats[key], value)
+				}
+			}
+		}
+	}
+}
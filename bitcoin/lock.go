@@ -0,0 +1,102 @@
+package bitcoin
+
+import (
+	"context"
+	"errors"
+	"forklol-collector/db"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+const (
+	// time between two checks that the writer lock is still held while syncing
+	lockCheckInterval = 10 * time.Second
+	// time between two attempts of a standby collector to take over the writer lock
+	lockRetryInterval = 15 * time.Second
+	// time the fencing check before a commit may take, see commit
+	lockFenceTimeout = 5 * time.Second
+)
+
+// ErrNotWriter is returned by Sync when another collector holds the writer lock of the coin
+var ErrNotWriter = errors.New("another collector is writing this coin")
+
+// ensureWriterLock takes the writer lock of the coin, or checks that it is still held when it was taken before. The
+// caller must hold TxLock.
+func (c *ChainSync) ensureWriterLock(ctx context.Context) error {
+	if c.writerLock != nil {
+		if time.Since(c.lockChecked) < lockCheckInterval {
+			return nil
+		}
+
+		if err := c.writerLock.Check(ctx); err == nil {
+			c.lockChecked = time.Now()
+			return nil
+		}
+
+		log.Printf("\u2718 Lost the %s writer lock.\n", c.Coin.Symbol)
+		c.writerLock.Release()
+		c.writerLock = nil
+	}
+
+	lock, err := db.TryWriterLock(ctx, c.Coin.Symbol)
+	if err != nil {
+		return err
+	}
+
+	if lock == nil {
+		return ErrNotWriter
+	}
+
+	log.Printf("Took the %s writer lock.\n", c.Coin.Symbol)
+	c.writerLock = lock
+	c.lockChecked = time.Now()
+
+	return nil
+}
+
+// commit commits tx only when the writer lock is still held on its connection, so a collector that lost the lock
+// since ensureWriterLock last checked it never writes a block. The caller must hold TxLock.
+func (c *ChainSync) commit(tx *sqlx.Tx) error {
+	if c.writerLock == nil {
+		tx.Rollback()
+		return ErrNotWriter
+	}
+
+	// not the sync context: a block that is being handled during shutdown is still committed
+	ctx, cancel := context.WithTimeout(context.Background(), lockFenceTimeout)
+	defer cancel()
+
+	if err := c.writerLock.Check(ctx); err != nil {
+		tx.Rollback()
+
+		log.Printf("\u2718 Lost the %s writer lock, rolled back.\n", c.Coin.Symbol)
+		c.writerLock.Release()
+		c.writerLock = nil
+
+		return ErrNotWriter
+	}
+
+	c.lockChecked = time.Now()
+
+	return tx.Commit()
+}
+
+// releaseWriterLock gives up the writer lock so a standby collector can take over
+func (c *ChainSync) releaseWriterLock() {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	if c.writerLock != nil {
+		c.writerLock.Release()
+		c.writerLock = nil
+	}
+}
+
+// lockWriter calls ensureWriterLock while holding TxLock
+func (c *ChainSync) lockWriter(ctx context.Context) error {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	return c.ensureWriterLock(ctx)
+}
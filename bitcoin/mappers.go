@@ -0,0 +1,196 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"forklol-collector/db"
+	"math"
+)
+
+// StatsMapper normalizes the (flattened) getblockstats output of one node implementation into a details row.
+// segwit tells whether the block should be stored with segwit details.
+type StatsMapper interface {
+	Map(coin string, height uint64, stats map[string]interface{}, segwit bool) *db.Details
+}
+
+// statsMappers has the mapper of every known implementation, unknown implementations use the Core mapper
+var statsMappers = map[string]StatsMapper{
+	ImplCore:     coreMapper{},
+	ImplKnots:    coreMapper{},
+	ImplABC:      bchMapper{},
+	ImplBCHN:     bchMapper{},
+	ImplElements: elementsMapper{},
+}
+
+// MapperFor returns the stats mapper of a node implementation
+func MapperFor(implementation string) StatsMapper {
+	if mapper, ok := statsMappers[implementation]; ok {
+		return mapper
+	}
+
+	return coreMapper{}
+}
+
+// coreMapper maps the stats of Bitcoin Core and Knots. Feerates are per virtual byte, the _old feerates per byte
+// are only returned by the patched fork.lol nodes (and by computeStats). Before segwit a virtual byte is a byte, so
+// the feerates of stock nodes are stored per byte there. Stock nodes have no median feerate, the 50th percentile is
+// used instead.
+type coreMapper struct{}
+
+func (coreMapper) Map(coin string, height uint64, stats map[string]interface{}, segwit bool) *db.Details {
+	s := statsReader(stats)
+	d := s.common(coin, height)
+
+	d.VAvgFeeRate = s.int("avgfeerate_old")
+	d.VMinFeeRate = s.int("minfeerate_old")
+	d.VMaxFeeRate = s.int("maxfeerate_old")
+	d.VMedianFeeRate = s.int("medianfeerate_old")
+
+	if !segwit {
+		d.VAvgFeeRate = s.first("avgfeerate_old", "avgfeerate")
+		d.VMinFeeRate = s.first("minfeerate_old", "minfeerate")
+		d.VMaxFeeRate = s.first("maxfeerate_old", "maxfeerate")
+		d.VMedianFeeRate = s.first("medianfeerate_old", "medianfeerate")
+	}
+
+	if segwit {
+		d.AvgFeeRate = s.int("avgfeerate")
+		d.MinFeeRate = s.int("minfeerate")
+		d.MaxFeeRate = s.int("maxfeerate")
+		d.MedianFeeRate = s.int("medianfeerate")
+		d.SegWitTxs = s.int("swtxs")
+		d.SegWitTotalSize = s.int("swtotal_size")
+		d.SegWitTotalWeight = s.int("swtotal_weight")
+		d.TotalWeight = s.int("total_weight")
+	}
+
+	s.percentiles(d)
+
+	if segwit && d.MedianFeeRate == nil {
+		d.MedianFeeRate = d.FeeRateP50
+	} else if !segwit && d.VMedianFeeRate == nil {
+		d.VMedianFeeRate = d.FeeRateP50
+	}
+
+	return d
+}
+
+// bchMapper maps the stats of Bitcoin ABC and Bitcoin Cash Node. There is no segwit and feerates are per byte, the
+// median feerate of stock nodes is their 50th percentile.
+type bchMapper struct{}
+
+func (bchMapper) Map(coin string, height uint64, stats map[string]interface{}, segwit bool) *db.Details {
+	s := statsReader(stats)
+	d := s.common(coin, height)
+
+	d.VAvgFeeRate = s.first("avgfeerate_old", "avgfeerate")
+	d.VMinFeeRate = s.first("minfeerate_old", "minfeerate")
+	d.VMaxFeeRate = s.first("maxfeerate_old", "maxfeerate")
+	d.VMedianFeeRate = s.first("medianfeerate_old", "medianfeerate")
+
+	s.percentiles(d)
+
+	if d.VMedianFeeRate == nil {
+		d.VMedianFeeRate = d.FeeRateP50
+	}
+
+	return d
+}
+
+// elementsMapper maps the stats of Elements (Liquid). Outputs are confidential, so the total output value is
+// meaningless, and there is no block subsidy.
+type elementsMapper struct{}
+
+func (elementsMapper) Map(coin string, height uint64, stats map[string]interface{}, segwit bool) *db.Details {
+	d := coreMapper{}.Map(coin, height, stats, segwit)
+
+	d.TotalOut = nil
+	d.Subsidy = int64Ptr(0)
+	d.Reward = d.Fee
+
+	return d
+}
+
+// statsReader reads values of a single block from flattened stats, missing or malformed values read as nil
+type statsReader map[string]interface{}
+
+// common fills the fields that every implementation reports the same way
+func (s statsReader) common(coin string, height uint64) *db.Details {
+	d := db.Details{
+		Coin:             coin,
+		Height:           height,
+		Time:             s.int("time"),
+		MedianTime:       s.int("mediantime"),
+		Txs:              s.int("txs"),
+		Inputs:           s.int("ins"),
+		Outputs:          s.int("outs"),
+		TotalOut:         s.int("total_out"),
+		TotalSize:        s.int("total_size"),
+		UTXOIncrease:     s.int("utxo_increase"),
+		UTXOSizeIncrease: s.int("utxo_size_inc"),
+		Subsidy:          s.int("subsidy"),
+		Fee:              s.int("totalfee"),
+		AvgFee:           s.int("avgfee"),
+		MinFee:           s.int("minfee"),
+		MaxFee:           s.int("maxfee"),
+		MedianFee:        s.int("medianfee"),
+	}
+
+	// only the patched fork.lol nodes report the reward
+	d.Reward = s.int("reward")
+	if d.Reward == nil && d.Subsidy != nil && d.Fee != nil {
+		d.Reward = int64Ptr(*d.Subsidy + *d.Fee)
+	}
+
+	return &d
+}
+
+func (s statsReader) percentiles(d *db.Details) {
+	vals, ok := s["feerate_percentiles"].([]interface{})
+	if !ok || len(vals) != 5 {
+		return
+	}
+
+	d.FeeRateP10, d.FeeRateP25, d.FeeRateP50 = toInt(vals[0]), toInt(vals[1]), toInt(vals[2])
+	d.FeeRateP75, d.FeeRateP90 = toInt(vals[3]), toInt(vals[4])
+}
+
+func (s statsReader) int(key string) *int64 {
+	return toInt(s[key])
+}
+
+// first returns the value of the first key that is set
+func (s statsReader) first(keys ...string) *int64 {
+	for _, key := range keys {
+		if v := s.int(key); v != nil {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// toInt converts a decoded json number (or an int from computeStats) to an int64
+func toInt(v interface{}) *int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64Ptr(int64(math.Round(n)))
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			return nil
+		}
+		return &i
+	case int64:
+		return &n
+	case uint64:
+		return int64Ptr(int64(n))
+	case int:
+		return int64Ptr(int64(n))
+	}
+
+	return nil
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
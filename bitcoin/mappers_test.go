@@ -0,0 +1,150 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"forklol-collector/db"
+	"testing"
+)
+
+// stats of a patched fork.lol Core node, as flattened by flattenStats from the range form of getblockstats
+var coreStats = map[string]interface{}{
+	"time": 1500000000.0, "mediantime": 1499999000.0, "txs": 2000.0, "ins": 5000.0, "outs": 6000.0,
+	"total_out": 1e12, "total_size": 999000.0, "utxo_increase": 1000.0, "utxo_size_inc": 80000.0,
+	"subsidy": 1250000000.0, "totalfee": 50000000.0, "avgfee": 25000.0, "minfee": 200.0, "maxfee": 1e6,
+	"medianfee": 10000.0, "avgfeerate": 60.0, "minfeerate": 1.0, "maxfeerate": 500.0, "medianfeerate": 40.0,
+	"avgfeerate_old": 50.0, "minfeerate_old": 1.0, "maxfeerate_old": 400.0, "medianfeerate_old": 30.0,
+	"swtxs": 1000.0, "swtotal_size": 500000.0, "swtotal_weight": 1500000.0, "total_weight": 3996000.0,
+	"feerate_percentiles": []interface{}{2.0, 10.0, 40.0, 80.0, 150.0},
+}
+
+func value(v *int64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func TestStatsMappers(t *testing.T) {
+	bchStats := map[string]interface{}{
+		"time": 1600000000.0, "txs": 300.0, "subsidy": 625000000.0, "totalfee": 10000.0,
+		"avgfeerate": 2.0, "minfeerate": 1.0, "maxfeerate": 5.0, "medianfeerate": json.Number("1"),
+	}
+
+	tests := []struct {
+		name     string
+		impl     string
+		stats    map[string]interface{}
+		segwit   bool
+		field    func(*db.Details) *int64
+		expected interface{}
+	}{
+		{"core reward from subsidy and fee", ImplCore, coreStats, true, func(d *db.Details) *int64 { return d.Reward }, int64(1300000000)},
+		{"core feerate per vbyte", ImplCore, coreStats, true, func(d *db.Details) *int64 { return d.AvgFeeRate }, int64(60)},
+		{"core feerate per byte", ImplCore, coreStats, true, func(d *db.Details) *int64 { return d.VAvgFeeRate }, int64(50)},
+		{"core segwit txs", ImplCore, coreStats, true, func(d *db.Details) *int64 { return d.SegWitTxs }, int64(1000)},
+		{"core before segwit", ImplCore, coreStats, false, func(d *db.Details) *int64 { return d.SegWitTxs }, nil},
+		{"core feerate before segwit", ImplCore, coreStats, false, func(d *db.Details) *int64 { return d.AvgFeeRate }, nil},
+		{"core percentile", ImplCore, coreStats, true, func(d *db.Details) *int64 { return d.FeeRateP75 }, int64(80)},
+		{"knots like core", ImplKnots, coreStats, true, func(d *db.Details) *int64 { return d.TotalWeight }, int64(3996000)},
+		{"unknown like core", ImplUnknown, coreStats, true, func(d *db.Details) *int64 { return d.MedianFeeRate }, int64(40)},
+		{"bch feerate per byte", ImplBCHN, bchStats, false, func(d *db.Details) *int64 { return d.VAvgFeeRate }, int64(2)},
+		{"bch json number", ImplABC, bchStats, false, func(d *db.Details) *int64 { return d.VMedianFeeRate }, int64(1)},
+		{"bch no segwit", ImplBCHN, bchStats, true, func(d *db.Details) *int64 { return d.AvgFeeRate }, nil},
+		{"bch missing value", ImplBCHN, bchStats, false, func(d *db.Details) *int64 { return d.Inputs }, nil},
+		{"elements no subsidy", ImplElements, coreStats, true, func(d *db.Details) *int64 { return d.Subsidy }, int64(0)},
+		{"elements reward is fee", ImplElements, coreStats, true, func(d *db.Details) *int64 { return d.Reward }, int64(50000000)},
+		{"elements confidential outputs", ImplElements, coreStats, true, func(d *db.Details) *int64 { return d.TotalOut }, nil},
+	}
+
+	for _, test := range tests {
+		d := MapperFor(test.impl).Map("TEST", 100, test.stats, test.segwit)
+
+		if d.Coin != "TEST" || d.Height != 100 {
+			t.Errorf("%s: mapped %s %d", test.name, d.Coin, d.Height)
+		}
+
+		if v := value(test.field(d)); v != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.name, v, test.expected)
+		}
+	}
+}
+
+func TestToInt(t *testing.T) {
+	tests := []struct {
+		in       interface{}
+		expected interface{}
+	}{
+		{1.4, int64(1)},
+		{1.5, int64(2)},
+		{json.Number("42"), int64(42)},
+		{json.Number("4.2"), nil},
+		{int64(-3), int64(-3)},
+		{uint64(7), int64(7)},
+		{9, int64(9)},
+		{"10", nil},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		if v := value(toInt(test.in)); v != test.expected {
+			t.Errorf("toInt(%#v) is %v, expected %v", test.in, v, test.expected)
+		}
+	}
+}
+
+// decoded returns stats the way they are decoded from a json-rpc response
+func decoded(t *testing.T, stats map[string]interface{}) map[string]interface{} {
+	j, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d map[string]interface{}
+	if err := json.Unmarshal(j, &d); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestStockStatsMappers(t *testing.T) {
+	core, bch, elements := decoded(t, coreStockStats), decoded(t, bchStockStats), decoded(t, elementsStockStats)
+
+	tests := []struct {
+		name     string
+		impl     string
+		stats    map[string]interface{}
+		segwit   bool
+		field    func(*db.Details) *int64
+		expected interface{}
+	}{
+		{"core feerate per vbyte", ImplCore, core, true, func(d *db.Details) *int64 { return d.AvgFeeRate }, int64(60)},
+		{"core no feerate per byte after segwit", ImplCore, core, true, func(d *db.Details) *int64 { return d.VAvgFeeRate }, nil},
+		{"core feerate per byte before segwit", ImplCore, core, false, func(d *db.Details) *int64 { return d.VMaxFeeRate }, int64(500)},
+		{"core median feerate", ImplCore, core, true, func(d *db.Details) *int64 { return d.MedianFeeRate }, int64(40)},
+		{"core median feerate before segwit", ImplCore, core, false, func(d *db.Details) *int64 { return d.VMedianFeeRate }, int64(40)},
+		{"core percentile", ImplCore, core, true, func(d *db.Details) *int64 { return d.FeeRateP10 }, int64(2)},
+		{"core reward", ImplCore, core, true, func(d *db.Details) *int64 { return d.Reward }, int64(1300000000)},
+		{"core segwit size", ImplCore, core, true, func(d *db.Details) *int64 { return d.SegWitTotalSize }, int64(500000)},
+		{"core inputs", ImplCore, core, true, func(d *db.Details) *int64 { return d.Inputs }, int64(5000)},
+		{"knots weight", ImplKnots, core, true, func(d *db.Details) *int64 { return d.TotalWeight }, int64(3996000)},
+		{"abc feerate per byte", ImplABC, bch, false, func(d *db.Details) *int64 { return d.VMinFeeRate }, int64(1)},
+		{"bchn feerate per byte", ImplBCHN, bch, false, func(d *db.Details) *int64 { return d.VAvgFeeRate }, int64(2)},
+		{"bchn median feerate", ImplBCHN, bch, false, func(d *db.Details) *int64 { return d.VMedianFeeRate }, int64(1)},
+		{"bchn percentile", ImplBCHN, bch, false, func(d *db.Details) *int64 { return d.FeeRateP90 }, int64(5)},
+		{"bchn no segwit", ImplBCHN, bch, false, func(d *db.Details) *int64 { return d.SegWitTxs }, nil},
+		{"bchn utxo size", ImplBCHN, bch, false, func(d *db.Details) *int64 { return d.UTXOSizeIncrease }, int64(7000)},
+		{"elements reward", ImplElements, elements, true, func(d *db.Details) *int64 { return d.Reward }, int64(100)},
+		{"elements total out", ImplElements, elements, true, func(d *db.Details) *int64 { return d.TotalOut }, nil},
+		{"elements segwit txs", ImplElements, elements, true, func(d *db.Details) *int64 { return d.SegWitTxs }, int64(2)},
+		{"elements percentile", ImplElements, elements, true, func(d *db.Details) *int64 { return d.FeeRateP90 }, int64(1)},
+	}
+
+	for _, test := range tests {
+		d := MapperFor(test.impl).Map("TEST", 100, test.stats, test.segwit)
+
+		if v := value(test.field(d)); v != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.name, v, test.expected)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+)
+
+// nodeMethod answers a call to a fakeNode with a result or an error code
+type nodeMethod func(params []json.RawMessage) (interface{}, int)
+
+type nodeCall struct {
+	Id     string            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// fakeNode is a json-rpc node that answers single and batch calls with the given methods, others fail with
+// -32601 like bitcoind does
+func fakeNode(methods map[string]nodeMethod) *httptest.Server {
+	answer := func(c nodeCall) map[string]interface{} {
+		resp := map[string]interface{}{"id": c.Id, "result": nil, "error": nil}
+
+		method, ok := methods[c.Method]
+		if !ok {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+			return resp
+		}
+
+		result, code := method(c.Params)
+		if code != 0 {
+			resp["error"] = map[string]interface{}{"code": code, "message": c.Method + " failed"}
+			return resp
+		}
+
+		resp["result"] = result
+		return resp
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var batch []nodeCall
+		if json.Unmarshal(body, &batch) == nil {
+			responses := make([]map[string]interface{}, len(batch))
+			for n, c := range batch {
+				responses[n] = answer(c)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var c nodeCall
+		json.Unmarshal(body, &c)
+
+		resp := answer(c)
+		if resp["error"] != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// stockBlockStats answers getblockstats like a stock node: a single height, scalar values. A second parameter has
+// to be the array of stats to return, a height there fails with -3 like it does on Core.
+func stockBlockStats(stats map[string]interface{}) nodeMethod {
+	return func(params []json.RawMessage) (interface{}, int) {
+		if len(params) > 1 && len(params[1]) > 0 && params[1][0] != '[' {
+			return nil, -3
+		}
+		return stats, 0
+	}
+}
+
+// rangeBlockStats answers getblockstats like the patched fork.lol nodes: a range of heights, array values
+func rangeBlockStats(stats map[string]interface{}) nodeMethod {
+	return func(params []json.RawMessage) (interface{}, int) {
+		if len(params) != 2 {
+			return nil, -1
+		}
+
+		ranged := map[string]interface{}{}
+		for key, val := range stats {
+			ranged[key] = []interface{}{val}
+		}
+		return ranged, 0
+	}
+}
+
+// stock getblockstats output of the supported implementations
+var (
+	coreStockStats = map[string]interface{}{
+		"avgfee": 25000, "avgfeerate": 60, "avgtxsize": 499, "blockhash": "hash", "height": 500000,
+		"feerate_percentiles": []int{2, 10, 40, 80, 150}, "ins": 5000, "maxfee": 1000000, "maxfeerate": 500,
+		"maxtxsize": 10000, "medianfee": 10000, "mediantime": 1499999000, "mediantxsize": 250, "minfee": 200,
+		"minfeerate": 1, "mintxsize": 150, "outs": 6000, "subsidy": 1250000000, "swtotal_size": 500000,
+		"swtotal_weight": 1500000, "swtxs": 1000, "time": 1500000000, "total_out": 1000000000000,
+		"total_size": 999000, "total_weight": 3996000, "totalfee": 50000000, "txs": 2000, "utxo_increase": 1000,
+		"utxo_size_inc": 80000,
+	}
+
+	// Bitcoin Cash Node and ABC have no segwit fields and feerates per byte
+	bchStockStats = map[string]interface{}{
+		"avgfee": 400, "avgfeerate": 2, "avgtxsize": 300, "blockhash": "hash", "height": 650000,
+		"feerate_percentiles": []int{1, 1, 1, 2, 5}, "ins": 600, "maxfee": 2000, "maxfeerate": 5, "maxtxsize": 900,
+		"medianfee": 230, "mediantime": 1600000000, "mediantxsize": 226, "minfee": 100, "minfeerate": 1,
+		"mintxsize": 150, "outs": 700, "subsidy": 625000000, "time": 1600000500, "total_out": 50000000000,
+		"total_size": 90000, "totalfee": 120000, "txs": 301, "utxo_increase": 100, "utxo_size_inc": 7000,
+	}
+
+	// Elements outputs are confidential, the total output value is reported as 0
+	elementsStockStats = map[string]interface{}{
+		"avgfee": 50, "avgfeerate": 0, "avgtxsize": 5000, "blockhash": "hash", "height": 1000000,
+		"feerate_percentiles": []int{0, 0, 0, 0, 1}, "ins": 2, "maxfee": 60, "maxfeerate": 1, "maxtxsize": 6000,
+		"medianfee": 50, "mediantime": 1600000000, "mediantxsize": 5000, "minfee": 40, "minfeerate": 0,
+		"mintxsize": 4000, "outs": 6, "subsidy": 0, "swtotal_size": 10000, "swtotal_weight": 12000, "swtxs": 2,
+		"time": 1600000060, "total_out": 0, "total_size": 10000, "total_weight": 12000, "totalfee": 100, "txs": 3,
+		"utxo_increase": 4, "utxo_size_inc": 1000,
+	}
+)
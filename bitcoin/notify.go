@@ -0,0 +1,144 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/hex"
+	"forklol-collector/zmq"
+	"log"
+	"time"
+)
+
+const (
+	// how often a zmq subscription checks whether the zmq url changed
+	zmqCheckInterval = 30 * time.Second
+	// maximum wait between two attempts to connect to a zmq publisher
+	zmqMaxRetryDelay = time.Minute
+)
+
+// ZMQUrl returns the zmq endpoint the coin announces new blocks on, empty when it has none
+func (c *ChainSync) ZMQUrl() string {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	return c.Coin.ZMQUrl
+}
+
+// WatchBlocks subscribes to the hashblock notifications of the coin's zmq endpoint and wakes up Run for every
+// announced block. It reconnects with backoff and returns when the syncer is stopped or ctx is cancelled. While the
+// coin has no zmq endpoint it only waits for one to be configured.
+func (c *ChainSync) WatchBlocks(ctx context.Context) {
+	delay := time.Second
+
+	for {
+		url := c.ZMQUrl()
+		if url == "" {
+			if !c.wait(ctx, zmqCheckInterval) {
+				return
+			}
+			continue
+		}
+
+		dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		sub, err := zmq.Subscribe(dialCtx, url, "hashblock")
+		cancel()
+
+		if err != nil {
+			log.Printf("Could not subscribe to %s zmq notifications at %s: %s\n", c.Coin.Symbol, url, err.Error())
+
+			if !c.wait(ctx, delay) {
+				return
+			}
+
+			if delay *= 2; delay > zmqMaxRetryDelay {
+				delay = zmqMaxRetryDelay
+			}
+			continue
+		}
+
+		log.Printf("Subscribed to %s zmq notifications at %s\n", c.Coin.Symbol, url)
+		delay = time.Second
+
+		c.receiveBlocks(ctx, sub, url)
+		sub.Close()
+
+		if ctx.Err() != nil || c.Stopped() {
+			return
+		}
+	}
+}
+
+// receiveBlocks passes announced blocks on to Run until the subscription fails, the zmq url changes, the syncer is
+// stopped or ctx is cancelled
+func (c *ChainSync) receiveBlocks(ctx context.Context, sub *zmq.Subscriber, url string) {
+	msgs := make(chan [][]byte)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	// stops when sub is closed by the caller or receiveBlocks returns
+	go func() {
+		for {
+			msg, err := sub.Receive()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case msgs <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	t := time.NewTicker(zmqCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case msg := <-msgs:
+			if len(msg) < 2 || string(msg[0]) != "hashblock" {
+				continue
+			}
+
+			log.Printf("\u26A1 New %s block %s announced over zmq\n", c.Coin.Symbol, hex.EncodeToString(msg[1]))
+
+			// a sync that is already pending will pick up this block as well
+			select {
+			case c.announced <- struct{}{}:
+			default:
+			}
+
+		case err := <-errs:
+			log.Printf("Lost %s zmq subscription at %s: %s\n", c.Coin.Symbol, url, err.Error())
+			return
+
+		case <-t.C:
+			if c.ZMQUrl() != url {
+				return
+			}
+
+		case <-ctx.Done():
+			return
+
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// wait sleeps for d and returns false when the syncer was stopped or ctx was cancelled in the meantime
+func (c *ChainSync) wait(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-c.stop:
+		return false
+	}
+}
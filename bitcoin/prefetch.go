@@ -0,0 +1,228 @@
+package bitcoin
+
+import (
+	"context"
+	"forklol-collector/config"
+	"forklol-collector/rpc"
+	"sync"
+)
+
+const (
+	// number of blocks a worker fetches in one batch of rpc calls
+	prefetchBatch = 10
+	// number of batches per worker that may be fetched ahead of the block that is being handled
+	prefetchWindow = 4
+
+	// the same for blocks with all their transactions, which take up to a few MB each while they are decoded
+	txBatch  = 2
+	txWindow = 1
+)
+
+// fetchMode is how fetchBlocks gets the stats of the blocks
+type fetchMode int
+
+const (
+	// block headers and getblockstats of a stock node
+	fetchBlockStats fetchMode = iota
+	// block headers and the range form of getblockstats of the patched fork.lol nodes
+	fetchRangeStats
+	// blocks with their transactions (getblock verbosity 2), the stats are computed from them
+	fetchTransactions
+	// block headers only, the details are collected later by collectMissingDetails
+	fetchHeaders
+)
+
+// fetchedBlock is a block (and its stats) that was fetched from bitcoind ahead of handling it
+type fetchedBlock struct {
+	height uint64
+	block  *rpc.Block
+	stats  *map[string]interface{}
+	err    error
+}
+
+// fetchedBatch holds the fetched blocks of one batch, starting at height from
+type fetchedBatch struct {
+	from   uint64
+	blocks []*fetchedBlock
+}
+
+// prefetch fetches the blocks from height from up to to in batches with a pool of workers. The blocks are delivered
+// in height order on the returned channel, which is closed after the last block or after a block that failed to
+// fetch. Cancelling ctx stops all workers, every goroutine that is started is added to wg. Stats computed from
+// transactions use the given snapshot of the chain params.
+func (c *ChainSync) prefetch(ctx context.Context, wg *sync.WaitGroup, from, to uint64, workers int, mode fetchMode, params config.ChainParams) <-chan *fetchedBlock {
+	quit := ctx.Done()
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	size, ahead := uint64(prefetchBatch), prefetchWindow
+	if mode == fetchTransactions {
+		size, ahead = txBatch, txWindow
+	}
+
+	window := make(chan struct{}, workers*ahead)
+	jobs := make(chan uint64)
+	results := make(chan *fetchedBatch, workers)
+	ordered := make(chan *fetchedBlock)
+
+	wg.Add(2 + workers)
+
+	// hand out batches in order, but never more than the window ahead of the handled block
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+
+		for h := from; h <= to; h += size {
+			select {
+			case window <- struct{}{}:
+			case <-quit:
+				return
+			}
+
+			select {
+			case jobs <- h:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for n := 0; n < workers; n++ {
+		go func() {
+			defer wg.Done()
+
+			for start := range jobs {
+				end := start + size - 1
+				if end > to {
+					end = to
+				}
+
+				select {
+				case results <- c.fetchBlocks(ctx, start, end, mode, params):
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+	// put the fetched batches back in height order
+	go func() {
+		defer wg.Done()
+		defer close(ordered)
+
+		pending := map[uint64]*fetchedBatch{}
+
+		for next := from; next <= to; next += size {
+			batch, ok := pending[next]
+			for !ok {
+				select {
+				case fetched := <-results:
+					pending[fetched.from] = fetched
+				case <-quit:
+					return
+				}
+				batch, ok = pending[next]
+			}
+
+			delete(pending, next)
+
+			for _, fetched := range batch.blocks {
+				select {
+				case ordered <- fetched:
+				case <-quit:
+					return
+				}
+
+				if fetched.err != nil {
+					return
+				}
+			}
+
+			<-window
+		}
+	}()
+
+	return ordered
+}
+
+// fetchBlocks gets the hashes, block info and (depending on the mode) stats of the blocks between from and to with one
+// batch request each. When a batch fails, its first block carries the error.
+func (c *ChainSync) fetchBlocks(ctx context.Context, from, to uint64, mode fetchMode, params config.ChainParams) *fetchedBatch {
+	batch := fetchedBatch{
+		from: from,
+	}
+
+	fail := func(err error) *fetchedBatch {
+		batch.blocks = []*fetchedBlock{{height: from, err: err}}
+		return &batch
+	}
+
+	client := c.Coin.RPCClient()
+
+	hashes, err := client.GetBlockHashes(ctx, from, to)
+	if err != nil {
+		return fail(err)
+	}
+
+	if mode == fetchTransactions {
+		blocks, err := c.getTxBlocks(ctx, hashes)
+		if err != nil {
+			return fail(err)
+		}
+
+		batch.blocks = make([]*fetchedBlock, len(blocks))
+		for n, block := range blocks {
+			// a copy of the header, so the transactions are not kept in memory until the block is handled
+			header := block.Block
+
+			batch.blocks[n] = &fetchedBlock{
+				height: from + uint64(n),
+				block:  &header,
+				stats:  computeStats(c.Coin.Symbol, params, block),
+			}
+		}
+
+		return &batch
+	}
+
+	// the block info used for blocks and hashrates is all in the header, getblock would add the whole txid list
+	blocks, err := client.GetBlockHeaders(ctx, hashes)
+	if err != nil {
+		return fail(err)
+	}
+
+	batch.blocks = make([]*fetchedBlock, len(blocks))
+	for n, block := range blocks {
+		batch.blocks[n] = &fetchedBlock{
+			height: from + uint64(n),
+			block:  block,
+		}
+	}
+
+	switch mode {
+	case fetchBlockStats:
+		stats, err := client.GetStockBlockStatsRange(ctx, from, to)
+		if err != nil {
+			return fail(err)
+		}
+
+		for n := range batch.blocks {
+			batch.blocks[n].stats = c.stockStats(stats[n])
+		}
+
+	case fetchRangeStats:
+		stats, err := client.GetBlockStatsRange(ctx, from, to)
+		if err != nil {
+			return fail(err)
+		}
+
+		for n := range batch.blocks {
+			batch.blocks[n].stats = c.flattenStats(stats[n])
+		}
+	}
+
+	return &batch
+}
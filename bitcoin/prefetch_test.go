@@ -0,0 +1,153 @@
+package bitcoin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"forklol-collector/config"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// headerNode serves getblockhash and getblockheader batches after a random delay, so the batches of the workers
+// finish out of order. The hash of a height is "hash<height>", getblockhash fails for height fail.
+func headerNode(fail uint64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var calls []struct {
+			Id     string            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(body, &calls)
+
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+		responses := make([]map[string]interface{}, len(calls))
+		for n, c := range calls {
+			resp := map[string]interface{}{"id": c.Id, "result": nil, "error": nil}
+
+			switch c.Method {
+			case "getblockhash":
+				var h uint64
+				json.Unmarshal(c.Params[0], &h)
+				if h == fail {
+					resp["error"] = map[string]interface{}{"code": -8, "message": "Block height out of range"}
+				} else {
+					resp["result"] = fmt.Sprintf("hash%d", h)
+				}
+			case "getblockheader":
+				var hash string
+				var h uint64
+				json.Unmarshal(c.Params[0], &hash)
+				fmt.Sscanf(hash, "hash%d", &h)
+				resp["result"] = map[string]interface{}{"hash": hash, "height": h}
+			}
+
+			responses[n] = resp
+		}
+
+		json.NewEncoder(w).Encode(responses)
+	}))
+}
+
+func testSyncer(t *testing.T, url string) *ChainSync {
+	retries := 0
+	coin, err := NewCoin(config.CoinOptions{
+		Symbol:     "TEST",
+		Endpoints:  []config.EndpointOptions{{URL: url}},
+		RPCTimeout: 5,
+		RPCRetries: &retries,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewChainSync(coin)
+}
+
+func TestPrefetchOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to uint64
+		workers  int
+		fail     uint64
+		last     uint64
+	}{
+		{"one worker", 0, 25, 1, 1000, 25},
+		{"more workers than batches", 5, 17, 8, 1000, 17},
+		{"many batches", 100, 499, 4, 1000, 499},
+		{"single block", 7, 7, 3, 1000, 7},
+		// the failed batch starts at 50, its first block carries the error
+		{"failing block", 0, 99, 4, 57, 50},
+	}
+
+	for _, test := range tests {
+		node := headerNode(test.fail)
+		c := testSyncer(t, node.URL)
+
+		var wg sync.WaitGroup
+		blocks := c.prefetch(context.Background(), &wg, test.from, test.to, test.workers, fetchHeaders, config.BITCOIN_CHAIN_PARAMS)
+
+		next, failed := test.from, false
+		for fetched := range blocks {
+			if fetched.height != next {
+				t.Fatalf("%s: got block %d, expected %d", test.name, fetched.height, next)
+			}
+
+			if fetched.err != nil {
+				failed = true
+			} else if fetched.block.Hash != fmt.Sprintf("hash%d", next) {
+				t.Errorf("%s: block %d has hash %s", test.name, next, fetched.block.Hash)
+			}
+
+			next++
+		}
+
+		// the channel is closed after the last block or a failed one
+		if next-1 != test.last {
+			t.Errorf("%s: stopped at block %d, expected %d", test.name, next-1, test.last)
+		}
+
+		if failed != (test.fail <= test.to) {
+			t.Errorf("%s: failed is %t", test.name, failed)
+		}
+
+		wg.Wait()
+		node.Close()
+	}
+}
+
+func TestPrefetchCancel(t *testing.T) {
+	node := headerNode(1000)
+	defer node.Close()
+
+	c := testSyncer(t, node.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	blocks := c.prefetch(ctx, &wg, 0, 999, 4, fetchHeaders, config.BITCOIN_CHAIN_PARAMS)
+
+	// the workers stop after a cancel even though nobody reads the remaining blocks
+	<-blocks
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prefetch goroutines still running after cancel")
+	}
+}
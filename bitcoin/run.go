@@ -0,0 +1,105 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// wait before the first retry of a failed sync, doubled after every failure
+	minSyncBackoff = 5 * time.Second
+	// maximum wait between two failed syncs
+	maxSyncBackoff = 5 * time.Minute
+)
+
+// PollInterval returns the time between two syncs of the coin when no blocks are announced
+func (c *ChainSync) PollInterval() time.Duration {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	return c.Coin.PollInterval
+}
+
+// Run syncs the coin in its own loop until the syncer is stopped or ctx is cancelled: every poll interval, and right
+// away when its node announces a block over zmq. A failing (or panicking) sync is retried with exponential backoff.
+//
+// Only the collector that holds the writer lock of the coin syncs it, others stand by and take over when it dies.
+func (c *ChainSync) Run(ctx context.Context) {
+	go c.WatchBlocks(ctx)
+	defer c.releaseWriterLock()
+
+	backoff := time.Duration(0)
+	standby := false
+
+	for {
+		err := c.safeSync(ctx)
+
+		if c.Stopped() || ctx.Err() != nil {
+			return
+		}
+
+		if err == ErrNotWriter && !standby {
+			log.Printf("Another collector is writing %s, standing by.\n", c.Coin.Symbol)
+		}
+		standby = err == ErrNotWriter
+
+		wait := c.PollInterval()
+		if standby {
+			wait = lockRetryInterval
+		} else if err != nil {
+			if backoff *= 2; backoff < minSyncBackoff {
+				backoff = minSyncBackoff
+			} else if backoff > maxSyncBackoff {
+				backoff = maxSyncBackoff
+			}
+
+			wait = backoff
+			log.Printf("%s sync failed, retrying in %s.\n", c.Coin.Symbol, wait)
+		} else {
+			backoff = 0
+
+			// a header-only sync has more details to collect
+			c.TxLock.Lock()
+			if c.detailsPending {
+				wait = 0
+			}
+			c.TxLock.Unlock()
+		}
+
+		// a failing coin keeps backing off, even when new blocks come in
+		announced := c.announced
+		if backoff > 0 {
+			announced = nil
+		}
+
+		t := time.NewTimer(wait)
+
+		select {
+		case <-t.C:
+		case <-announced:
+		case <-ctx.Done():
+		case <-c.stop:
+		}
+
+		t.Stop()
+
+		if c.Stopped() || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// safeSync runs Sync and turns a panic into an error, so one failing coin can not take down the collector
+func (c *ChainSync) safeSync(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("\u2718 %s sync panicked: %v\n%s", c.Coin.Symbol, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return c.Sync(ctx)
+}
@@ -0,0 +1,102 @@
+package bitcoin
+
+import (
+	"context"
+	"forklol-collector/db"
+	"forklol-collector/rpc"
+	"log"
+)
+
+// seedWindow is the time (in seconds) before the start height that is seeded, the longest hashrate window (d30)
+const seedWindow = 30 * 24 * 3600
+
+// startHeight returns the first block that is collected into a fresh database
+func (c *ChainSync) startHeight() uint64 {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	return c.Coin.StartHeight
+}
+
+// seed prepares a fresh database to start collecting at the start height of the coin. The blocks of the 30 days
+// before it are stored without details or hashrates, so the hashrate windows of the first collected blocks are
+// complete (work needs no seeding, it comes from the node's chainwork). It returns the height and hash of the last
+// seeded block, which is where the sync continues.
+func (c *ChainSync) seed(ctx context.Context) (uint64, string, error) {
+	start := c.startHeight()
+	if start <= 1 {
+		return 0, "", nil
+	}
+
+	client := c.Coin.RPCClient()
+	headers := make([]*rpc.Block, 0)
+	cutoff := uint64(0)
+
+	// walk back from the start height in chunks until the window is covered
+	for end := start - 1; ; end -= verifyChunkSize {
+		from := uint64(0)
+		if end >= verifyChunkSize {
+			from = end - verifyChunkSize + 1
+		}
+
+		hashes, err := client.GetBlockHashes(ctx, from, end)
+		if err != nil {
+			return 0, "", err
+		}
+
+		chunk, err := client.GetBlockHeaders(ctx, hashes)
+		if err != nil {
+			return 0, "", err
+		}
+
+		if cutoff == 0 && chunk[len(chunk)-1].Time > seedWindow {
+			cutoff = chunk[len(chunk)-1].Time - seedWindow
+		}
+
+		headers = append(chunk, headers...)
+
+		if from == 0 || chunk[0].Time < cutoff {
+			break
+		}
+	}
+
+	for len(headers) > 1 && headers[0].Time < cutoff {
+		headers = headers[1:]
+	}
+
+	log.Printf("Seeding %s with blocks %d to %d before start height %d\n", c.Coin.Symbol, headers[0].Height, start-1, start)
+
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	if err := c.ensureWriterLock(ctx); err != nil {
+		return 0, "", err
+	}
+
+	tx, err := db.GetDB().Beginx()
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, header := range headers {
+		chainwork, err := parseChainWork(header.ChainWork)
+		if err != nil {
+			tx.Rollback()
+			return 0, "", err
+		}
+
+		_, err = db.InsertBlock(tx, c.Coin.Symbol, header.Hash, header.PrevHash, chainwork.String(), header.Height,
+			header.Time, header.Difficulty, workFromChainWork(chainwork))
+		if err != nil {
+			tx.Rollback()
+			return 0, "", err
+		}
+	}
+
+	if err := c.commit(tx); err != nil {
+		return 0, "", err
+	}
+
+	last := headers[len(headers)-1]
+	return last.Height, last.Hash, nil
+}
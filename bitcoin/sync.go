@@ -1,207 +1,659 @@
 package bitcoin
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"forklol-collector/config"
 	"forklol-collector/db"
 	"forklol-collector/rpc"
 	"github.com/jmoiron/sqlx"
 	"sync"
-	"errors"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// number of heights the details pass checks for missing details at once
+	detailsChunkSize = 1000
+
+	// number of block hashes compared at once while looking for the common ancestor of a reorg
+	reorgChunkSize = 100
+	// deepest reorg that is rolled back, anything deeper needs verify -repair
+	maxReorgDepth = 2000
+)
+
+// ErrNoCommonAncestor is returned when no stored block within maxReorgDepth matches the node's chain
+var ErrNoCommonAncestor = errors.New("no common ancestor with the node's chain found among the stored blocks")
+
 type ChainSync struct {
 	Coin   Coin
 	TxLock sync.Mutex
+
+	stopped  int32
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// receives a value when a new block is announced over zmq
+	announced chan struct{}
+
+	// makes sure no other collector writes this coin, see ensureWriterLock
+	writerLock  *db.WriterLock
+	lockChecked time.Time
+
+	// what the node supports, probed on the first sync, see loadCapabilities
+	caps *Capabilities
+
+	// set when the node rejects getblock verbosity 3, see getTxBlocks
+	noPrevouts int32
+
+	// progress of the details pass of a header-only sync, see collectMissingDetails
+	detailsFrom    uint64
+	detailsPending bool
 }
 
-func NewChainSync(coin Coin) ChainSync {
-	return ChainSync{
-		Coin: coin,
+func NewChainSync(coin Coin) *ChainSync {
+	return &ChainSync{
+		Coin:        coin,
+		stop:        make(chan struct{}),
+		announced:   make(chan struct{}, 1),
+		detailsFrom: coin.StartHeight,
 	}
 }
 
-// SyncAll brings the database up to date with the bitcoind chain. It does not handle reorgs.
-func (c ChainSync) Sync(done chan bool) {
+// UpdateCoin applies changed coin options (like rpc credentials) in between two blocks
+func (c *ChainSync) UpdateCoin(coin Coin) error {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	if err := c.Coin.RPCClient().SetEndpoints(coin.Endpoints); err != nil {
+		return err
+	}
+	c.Coin.RPCClient().SetOptions(coin.rpcOptions())
+
+	c.Coin.Endpoints = coin.Endpoints
+	c.Coin.RPCStats = coin.RPCStats
+	c.Coin.SegWit = coin.SegWit
+	c.Coin.ZMQUrl = coin.ZMQUrl
+	c.Coin.PollInterval = coin.PollInterval
+	c.Coin.FetchWorkers = coin.FetchWorkers
+	c.Coin.HeaderSync = coin.HeaderSync
+	c.Coin.StartHeight = coin.StartHeight
+	c.Coin.Params = coin.Params
+	c.Coin.RPCTimeout = coin.RPCTimeout
+	c.Coin.RPCRetries = coin.RPCRetries
+
+	// the endpoints may point to another node now
+	c.caps = nil
+	atomic.StoreInt32(&c.noPrevouts, 0)
+
+	return nil
+}
+
+// Stop makes a running sync return after the block it is currently handling has been committed
+func (c *ChainSync) Stop() {
+	atomic.StoreInt32(&c.stopped, 1)
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// Stopped returns true when Stop() has been called
+func (c *ChainSync) Stopped() bool {
+	return atomic.LoadInt32(&c.stopped) == 1
+}
+
+// Sync brings the database up to date with the bitcoind chain. Blocks that were orphaned by a reorg are rolled
+// back to the last common block before syncing forward. The error that stopped the sync is logged and returned,
+// ErrNotWriter when another collector holds the writer lock of the coin.
+func (c *ChainSync) Sync(ctx context.Context) error {
+	if err := c.lockWriter(ctx); err != nil {
+		return err
+	}
+
 	prevHeight, prevHash, err := db.GetLastBlock(c.Coin.Symbol)
-	if err != nil {
+	fresh := err == sql.ErrNoRows
+	if err != nil && !fresh {
 		log.Printf("Could not get last %s block from database: %s\n", c.Coin.Symbol, err.Error())
-		return
+		return err
 	}
 
 	client := c.Coin.RPCClient()
 
-	height, hash, err := client.GetLastBlock()
+	if err := client.HealthCheck(ctx); err != nil {
+		c.handleRPCError("reach any endpoint", err)
+		return err
+	}
+
+	c.TxLock.Lock()
+	err = c.loadCapabilities(ctx)
+	c.TxLock.Unlock()
+
 	if err != nil {
-		log.Printf("Could not get last block from %s chain: %s.\n", c.Coin.Symbol, err.Error())
-		return
+		c.handleRPCError("detect node capabilities", err)
+		return err
+	}
+
+	height, hash, err := client.GetLastBlock(ctx)
+	if err != nil {
+		c.handleRPCError("get last block", err)
+		return err
+	}
+
+	if fresh {
+		if start := c.startHeight(); start > height+1 {
+			log.Printf("%s node is below the start height (%d < %d), waiting for it to catch up.\n", c.Coin.Symbol, height, start)
+			return nil
+		}
+
+		if prevHeight, prevHash, err = c.seed(ctx); err != nil {
+			c.handleRPCError("seed the blocks before the start height", err)
+			return err
+		}
+	}
+
+	if prevHeight > height {
+		// a node that is behind may be on another chain, its tip is compared with the stored block at that height
+		stored, err := db.GetBlock(c.Coin.Symbol, height)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Could not get %s block %d from database: %s\n", c.Coin.Symbol, height, err.Error())
+			return err
+		}
+
+		if err == sql.ErrNoRows || stored.Hash == hash {
+			log.Printf("%s node is behind the database (%d < %d), waiting for it to catch up.\n", c.Coin.Symbol, height, prevHeight)
+			return nil
+		}
+
+		if prevHeight, err = c.rollbackReorg(ctx, prevHeight, height); err != nil {
+			c.handleRPCError("roll back reorg", err)
+			return err
+		}
+	} else if prevHeight > 0 {
+		// a fresh database without seeded blocks has no stored tip to compare
+		nodeHash := hash
+		if prevHeight < height {
+			nodeHash, err = client.GetBlockHash(ctx, prevHeight)
+			if err != nil {
+				c.handleRPCError(fmt.Sprintf("get blockhash at height %d", prevHeight), err)
+				return err
+			}
+		}
+
+		if nodeHash != prevHash {
+			if prevHeight, err = c.rollbackReorg(ctx, prevHeight, height); err != nil {
+				c.handleRPCError("roll back reorg", err)
+				return err
+			}
+		}
 	}
 
 	if prevHeight < height {
-		// @TODO check for reorg
 		log.Printf("Syncing %s chain to block %d (from %d, %d blocks)\n", c.Coin.Symbol, height, prevHeight, height-prevHeight)
 
-		c.syncFromHeight(prevHeight, height)
+		if err := c.syncFromHeight(ctx, prevHeight, height); err != nil {
+			return err
+		}
+	}
 
-	} else if prevHash != hash {
-		// @TODO check for reorg or new best
-	} else {
-		// no new block(s) found
+	return c.collectMissingDetails(ctx, height)
+}
+
+// handleRPCError logs a failed action and reacts to the kind of rpc error. Syncs that fail because the node is
+// unreachable, warming up or changing its chain are simply retried on the next sync.
+func (c *ChainSync) handleRPCError(action string, err error) {
+	switch {
+	case rpc.IsWarmingUp(err):
+		log.Printf("%s node is warming up, could not %s. Retrying on the next sync.\n", c.Coin.Symbol, action)
+
+	case rpc.IsTransportError(err):
+		log.Printf("%s node is unreachable, could not %s: %s.\n", c.Coin.Symbol, action, err.Error())
+
+	case rpc.IsBlockNotFound(err), rpc.IsHeightOutOfRange(err):
+		log.Printf("%s node changed its chain, could not %s: %s. A reorg will be handled on the next sync.\n", c.Coin.Symbol, action, err.Error())
+
+	case rpc.IsMethodNotFound(err):
+		if err.(*rpc.Error).Method == "getblockstats" {
+			log.Printf("%s node does not support getblockstats, computing block details from the blocks.\n", c.Coin.Symbol)
+
+			c.TxLock.Lock()
+			if c.caps != nil {
+				c.caps.BlockStats, c.caps.BlockStatsRange = false, false
+			}
+			// an rpc_stats option that forces stats on can not be honoured by this node
+			c.Coin.RPCStats = nil
+			c.TxLock.Unlock()
+			return
+		}
+		log.Printf("%s node does not support %s, could not %s.\n", c.Coin.Symbol, err.(*rpc.Error).Method, action)
+
+	default:
+		log.Printf("Could not %s on %s chain: %s.\n", action, c.Coin.Symbol, err.Error())
+	}
+}
+
+// rollbackReorg removes all stored blocks after the last block that is still part of the node's best chain and
+// returns the height of that common ancestor. The search starts at the lower of the stored and the node's tip.
+func (c *ChainSync) rollbackReorg(ctx context.Context, tipHeight, nodeHeight uint64) (uint64, error) {
+	from := tipHeight
+	if nodeHeight < from {
+		from = nodeHeight
+	}
+
+	ancestor, err := c.findCommonAncestor(ctx, from)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Printf("\u21BA Reorg detected on %s chain, rolling back %d blocks to common ancestor %d\n", c.Coin.Symbol, tipHeight-ancestor, ancestor)
+
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	tx, err := db.GetDB().Beginx()
+	if err != nil {
+		return 0, err
 	}
 
-	done <- true
+	if err := db.DeleteBlocksAfter(tx, c.Coin.Symbol, ancestor); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := c.commit(tx); err != nil {
+		return 0, err
+	}
+
+	if c.detailsFrom > ancestor+1 {
+		c.detailsFrom = ancestor + 1
+	}
+
+	return ancestor, nil
 }
 
-// syncFromHeight will get new blocks from bitcoind and pass them to handleNewBlock for processing
-func (c ChainSync) syncFromHeight(prevHeight, height uint64) {
-	for h := prevHeight + 1; h <= height; h++ {
-		hash, err := c.Coin.RPCClient().GetBlockHash(h)
+// findCommonAncestor walks back from the given height until the stored blockhash matches the one of the node. It
+// gives up with ErrNoCommonAncestor below the lowest stored block or after maxReorgDepth blocks, the node is then most
+// likely on another chain.
+func (c *ChainSync) findCommonAncestor(ctx context.Context, height uint64) (uint64, error) {
+	lowest, _, err := db.GetHeightRange(c.Coin.Symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	if height >= maxReorgDepth && height-maxReorgDepth > lowest {
+		lowest = height - maxReorgDepth
+	}
+
+	return commonAncestor(lowest, height, reorgChunkSize, func(start, end uint64) (map[uint64]string, []string, error) {
+		nodeHashes, err := c.Coin.RPCClient().GetBlockHashes(ctx, start, end)
 		if err != nil {
-			log.Printf("Error getting %s blockhash at height %d, aborting sync at this height point.\n", c.Coin.Symbol, h)
-			break
+			return nil, nil, err
 		}
 
-		// get block info based on hash
-		block, err := c.Coin.RPCClient().GetBlock(hash)
+		blocks, err := db.GetBlocksBetween(c.Coin.Symbol, start, end)
 		if err != nil {
-			log.Printf("Error getting %s block info at height %d, aborting sync at this height point.\n", c.Coin.Symbol, h)
+			return nil, nil, err
+		}
+
+		stored := make(map[uint64]string, len(*blocks))
+		for _, blk := range *blocks {
+			stored[blk.Height] = blk.Hash
+		}
+
+		return stored, nodeHashes, nil
+	})
+}
+
+// hashChunk returns the stored blockhashes by height and the node's blockhashes (in height order) from start to end
+type hashChunk func(start, end uint64) (map[uint64]string, []string, error)
+
+// commonAncestor walks back from height to lowest, in chunks of chunkSize blocks, and returns the highest height at
+// which the stored and the node's blockhash are the same. A missing stored block is treated like a mismatch.
+func commonAncestor(lowest, height, chunkSize uint64, chunk hashChunk) (uint64, error) {
+	for end := height; end >= lowest; end -= chunkSize {
+		start := lowest
+		if end-lowest >= chunkSize {
+			start = end - chunkSize + 1
+		}
+
+		stored, nodeHashes, err := chunk(start, end)
+		if err != nil {
+			return 0, err
+		}
+
+		for h := end; h >= start; h-- {
+			if hash, ok := stored[h]; ok && h-start < uint64(len(nodeHashes)) && hash == nodeHashes[h-start] {
+				return h, nil
+			}
+
+			if h == 0 {
+				break
+			}
+		}
+
+		if start == lowest {
 			break
 		}
+	}
+
+	return 0, ErrNoCommonAncestor
+}
+
+// syncFromHeight will get new blocks from bitcoind and pass them to handleNewBlock for processing. Blocks are
+// prefetched by a pool of workers but handled one by one in height order.
+func (c *ChainSync) syncFromHeight(ctx context.Context, prevHeight, height uint64) error {
+	c.TxLock.Lock()
+	workers, mode, params := c.Coin.FetchWorkers, fetchBlockStats, c.Coin.Params
+	if c.Coin.HeaderSync {
+		mode = fetchHeaders
+	} else if !c.useBlockStats() {
+		mode = fetchTransactions
+	} else if c.useRangeStats() {
+		mode = fetchRangeStats
+	}
+	c.TxLock.Unlock()
+
+	// stops the prefetch workers when the sync ends early and waits for them to return
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-		log.Printf("\u2794 Handling new %s block %d, %s (%d left)", c.Coin.Symbol, h, hash, height-h)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for fetched := range c.prefetch(ctx, &wg, prevHeight+1, height, workers, mode, params) {
+		h := fetched.height
+
+		if c.Stopped() || ctx.Err() != nil {
+			log.Printf("%s sync stopped at height %d.\n", c.Coin.Symbol, h-1)
+			return nil
+		}
+
+		if fetched.err != nil {
+			c.handleRPCError(fmt.Sprintf("get block %d, aborting sync at this height point", h), fetched.err)
+			return fetched.err
+		}
+
+		block := fetched.block
+
+		log.Printf("\u2794 Handling new %s block %d, %s (%d left)", c.Coin.Symbol, h, block.Hash, height-h)
+		c.TxLock.Lock()
 		start := time.Now()
-		err = c.handleNewBlock(block)
+		err := c.ensureWriterLock(ctx)
+		if err == nil {
+			err = c.handleNewBlock(block, fetched.stats)
+		}
 		end := time.Now()
-		if err != nil {
+		c.TxLock.Unlock()
+		if err == ErrNotWriter {
+			log.Printf("\u2718 Another collector took over %s, stopping sync at block %d\n", c.Coin.Symbol, block.Height-1)
+			return err
+		} else if err == db.ErrBrokenChain {
+			log.Printf("\u2718 %s block %d does not build on the stored chain, a reorg will be handled on the next sync\n", c.Coin.Symbol, block.Height)
+			return err
+		} else if err != nil {
 			log.Printf("\u2718 Error handling %s block %d, skipping other blocks\n", c.Coin.Symbol, block.Height)
-			break
+			return err
 		}
-		log.Printf("\u2714 New %s block %d handled in %s\n", c.Coin.Symbol, block.Height, end.Sub(start))
+		log.Printf("\u2714 New %s block %d handled in %s (served by %s)\n", c.Coin.Symbol, block.Height, end.Sub(start), block.ServedBy)
 
 	}
+
+	c.logProjection(params, height)
+
+	return nil
 }
 
-// handleNewBlock will insert the block into the database and collect some more information about it after
-func (c ChainSync) handleNewBlock(block *rpc.Block) error {
-	done := make(chan *collectResult)
-	if c.Coin.RPCStats == true {
-		go c.asyncCollectStats(done, block.Height)
+// logProjection logs the supply at height and when the next retarget and halving are expected from the chain params
+func (c *ChainSync) logProjection(params config.ChainParams, height uint64) {
+	spacing := time.Duration(params.TargetSpacing) * time.Second
+	supply := float64(params.Supply(height)) / 1e8
+
+	if retarget := params.NextRetarget(height); retarget > 0 {
+		algorithm, _ := params.Difficulty(retarget)
+		log.Printf("%s supply %.8f at block %d, next %s retarget at block %d (in ~%s)\n", c.Coin.Symbol, supply, height, algorithm, retarget, time.Duration(retarget-height)*spacing)
 	} else {
-		go func() {
-			done <- nil
-		}()
+		log.Printf("%s supply %.8f at block %d\n", c.Coin.Symbol, supply, height)
 	}
 
-	tx := db.GetDB().MustBegin()
+	if halving := params.NextHalving(height); halving > 0 {
+		log.Printf("%s next halving at block %d (in ~%s)\n", c.Coin.Symbol, halving, time.Duration(halving-height)*spacing)
+	}
+}
 
-	prevBlock, err := db.GetBlock(c.Coin.Symbol, block.Height-1)
-	work := float64(0.0)
+// handleNewBlock will insert the block and its prefetched stats (when it has any) into the
+// database. The caller must hold TxLock.
+func (c *ChainSync) handleNewBlock(block *rpc.Block, stats *map[string]interface{}) error {
+	tx, err := db.GetDB().Beginx()
+	if err != nil {
+		log.Printf("Could not begin db transaction: %s\n", err.Error())
+		return err
+	}
 
-	if err == nil {
-		work = prevBlock.Work
+	chainwork, err := parseChainWork(block.ChainWork)
+	if err != nil {
+		log.Printf("Could not read chainwork of %s block %d: %s\n", c.Coin.Symbol, block.Height, err.Error())
+		tx.Rollback()
+		return err
 	}
 
 	_, err = db.InsertBlock(
 		tx,
 		c.Coin.Symbol,
 		block.Hash,
+		block.PrevHash,
+		chainwork.String(),
 		block.Height,
 		block.Time,
 		block.Difficulty,
-		work+block.Difficulty,
+		workFromChainWork(chainwork),
 	)
 
 	if err != nil {
-		<-done
-		close(done)
 		log.Printf("Could not insert block into database: %s\n", err.Error())
+		tx.Rollback()
 		return err
 	}
 
-	rates, err := c.determineHashrates(tx, block.Time)
+	rates, err := c.determineHashrates(tx, block.Height, block.Time)
 	if err != nil {
-		<-done
-		close(done)
 		log.Printf("Could not determine hashrate of %s block %d\n", c.Coin.Symbol, block.Height)
 		tx.Rollback()
 		return err
 	}
 
 	if err := db.InsertRates(tx, c.Coin.Symbol, block.Height, rates); err != nil {
-		<-done
-		close(done)
 		log.Printf("Could not insert hashrates of %s block %d\n", c.Coin.Symbol, block.Height)
 		tx.Rollback()
 		return err
 	}
 
-	if c.Coin.RPCStats == true {
-		select {
-		case stats := <-done:
-			if stats == nil {
-				tx.Rollback()
-				return errors.New("Could not get stats through RPC.")
+	if stats != nil {
+		if err := c.insertDetails(tx, block.Height, stats); err != nil {
+			log.Printf("Could not insert block details database: %s\n", err.Error())
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = c.commit(tx); err != nil {
+		log.Printf("Could not commit db transactions: %s.\n", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// collectMissingDetails is the second pass of a header-only sync, it collects the details of the stored blocks up to
+// tip that have none, oldest first. After a poll interval (or when a new block is announced) it gives way to the
+// next sync and leaves detailsPending set, so Run syncs again right away.
+func (c *ChainSync) collectMissingDetails(ctx context.Context, tip uint64) error {
+	c.TxLock.Lock()
+	enabled, from := c.Coin.HeaderSync, c.detailsFrom
+	if !enabled {
+		c.detailsPending = false
+	}
+	c.TxLock.Unlock()
+
+	if !enabled {
+		return nil
+	}
+
+	deadline := time.Now().Add(c.PollInterval())
+
+	for start := from; start <= tip; start += detailsChunkSize {
+		end := start + detailsChunkSize - 1
+		if end > tip {
+			end = tip
+		}
+
+		heights, err := db.GetHeightsMissingDetails(c.Coin.Symbol, start, end)
+		if err != nil {
+			log.Printf("Could not get %s blocks without details: %s\n", c.Coin.Symbol, err.Error())
+			return err
+		}
+
+		for _, h := range heights {
+			if c.Stopped() || ctx.Err() != nil {
+				return nil
 			}
 
-			if c.Coin.SegWit {
-				_, err = db.InsertDetails(tx, stats.Stats)
-			} else {
-				_, err = db.InsertDetailsNoSegwit(tx, stats.Stats)
+			if time.Now().After(deadline) || len(c.announced) > 0 {
+				log.Printf("Collected %s details up to block %d, continuing after the next sync\n", c.Coin.Symbol, h-1)
+				c.setDetailsProgress(h, true)
+				return nil
 			}
 
-			if err != nil {
-				log.Printf("Could not insert block details database: %s\n", err.Error())
+			c.TxLock.Lock()
+			err := c.ensureWriterLock(ctx)
+			if err == nil {
+				err = c.collectDetails(ctx, h)
+			}
+			c.TxLock.Unlock()
+
+			if err == ErrNotWriter {
+				return err
+			} else if err != nil {
+				c.handleRPCError(fmt.Sprintf("collect details of block %d", h), err)
+				c.setDetailsProgress(h, false)
 				return err
 			}
 		}
-		close(done)
-	}
 
-	if err = tx.Commit(); err != nil {
-		log.Printf("Could not commit db transactions: %s.\n", err.Error())
-		return err
+		c.setDetailsProgress(end+1, false)
 	}
 
 	return nil
 }
 
-func (c ChainSync) asyncCollectStats(done chan *collectResult, height uint64) {
-	stats, err := c.collectBlockStats(height)
+// setDetailsProgress stores the height the next details pass starts at
+func (c *ChainSync) setDetailsProgress(from uint64, pending bool) {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	c.detailsFrom, c.detailsPending = from, pending
+}
+
+// collectDetails fetches the statistics of an already stored block and inserts them into the details table.
+// The caller must hold TxLock.
+func (c *ChainSync) collectDetails(ctx context.Context, height uint64) error {
+	stats, err := c.collectBlockStats(ctx, height)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.GetDB().Beginx()
 	if err != nil {
-		done <- nil
-		return
+		return err
 	}
 
-	done <- &collectResult{
-		Stats: stats,
-		Error: nil,
+	if err := c.insertDetails(tx, height, stats); err != nil {
+		tx.Rollback()
+		return err
 	}
+
+	return c.commit(tx)
 }
 
-type collectResult struct {
-	Stats *map[string]interface{}
-	Error error
+// insertDetails maps the stats of the block at height with the mapper of the node implementation and stores them,
+// with segwit details when segwit was active at that height. The caller must hold TxLock.
+func (c *ChainSync) insertDetails(tx *sqlx.Tx, height uint64, stats *map[string]interface{}) error {
+	details := c.statsMapper().Map(c.Coin.Symbol, height, *stats, c.segwitAt(height))
+
+	_, err := db.InsertDetails(tx, details)
+	return err
 }
 
-// collectBlockStats will call rpc.GetBlockStats() and return the statistics for the block at the given height.
-func (c ChainSync) collectBlockStats(height uint64) (*map[string]interface{}, error) {
-	stats, err := c.Coin.RPCClient().GetBlockStats(height)
+// collectBlockStats returns the statistics for the block at the given height, from rpc.GetBlockStats() or computed
+// from the block when the node does not support getblockstats. The caller must hold TxLock.
+func (c *ChainSync) collectBlockStats(ctx context.Context, height uint64) (*map[string]interface{}, error) {
+	client := c.Coin.RPCClient()
+
+	if !c.useBlockStats() {
+		hash, err := client.GetBlockHash(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks, err := c.getTxBlocks(ctx, []string{hash})
+		if err != nil {
+			return nil, err
+		}
+
+		return computeStats(c.Coin.Symbol, c.Coin.Params, blocks[0]), nil
+	}
+
+	if !c.useRangeStats() {
+		stats, err := client.GetStockBlockStats(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.stockStats(stats), nil
+	}
+
+	stats, err := client.GetBlockStats(ctx, height)
 	if err != nil {
 		return nil, err
 	}
 
+	return c.flattenStats(stats), nil
+}
+
+// flattenStats turns the result of the range form of getblockstats into the stats of a single block, as read by a
+// StatsMapper
+func (c *ChainSync) flattenStats(stats *rpc.BlockStats) *map[string]interface{} {
 	flat := map[string]interface{}{
 		"coin": c.Coin.Symbol,
 	}
 
+	// every value is an array with one entry per block of the requested range
 	for key, val := range *stats {
-		flat[key] = val[0]
+		if len(val) > 0 {
+			flat[key] = val[0]
+		}
 	}
 
-	return &flat, nil
+	return &flat
 }
 
-func (c ChainSync) determineHashrates(tx *sqlx.Tx, time uint64) (*map[string]float64, error) {
+// stockStats returns the result of getblockstats of a stock node as the stats read by a StatsMapper
+func (c *ChainSync) stockStats(stats rpc.StockBlockStats) *map[string]interface{} {
+	flat := map[string]interface{}{
+		"coin": c.Coin.Symbol,
+	}
+
+	for key, val := range stats {
+		flat[key] = val
+	}
+
+	return &flat
+}
+
+// determineHashrates estimates the hashrates of the windows that end at the block at height, from the stored blocks
+// up to that height
+func (c *ChainSync) determineHashrates(tx *sqlx.Tx, height, time uint64) (*map[string]float64, error) {
 	avgs := map[string]uint64{
 		"h3":  time - 3*3600,
 		"h6":  time - 6*3600,
@@ -213,9 +665,18 @@ func (c ChainSync) determineHashrates(tx *sqlx.Tx, time uint64) (*map[string]flo
 	}
 
 	rates := map[string]float64{}
+	spacing := float64(c.Coin.Params.TargetSpacing)
+
+	// signed blocks have no hashrate
+	if algorithm, _ := c.Coin.Params.Difficulty(height); algorithm == config.DIFF_NONE {
+		for avg := range avgs {
+			rates[avg] = 0
+		}
+		return &rates, nil
+	}
 
 	for avg, t := range avgs {
-		blocks, err := db.GetBlocksAfterGrouped(tx, c.Coin.Symbol, t)
+		blocks, err := db.GetBlocksAfterGrouped(tx, c.Coin.Symbol, t, height)
 		if err != nil {
 			return nil, err
 		}
@@ -240,7 +701,7 @@ func (c ChainSync) determineHashrates(tx *sqlx.Tx, time uint64) (*map[string]flo
 			n++
 
 			timeTaken := blk.Time - lastT
-			blocksExpected := float64(timeTaken) / 600.0
+			blocksExpected := float64(timeTaken) / spacing
 			blocksActually := num[blk.Difficulty]
 
 			factor := (float64(timeTaken) / float64(time-t))
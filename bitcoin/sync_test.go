@@ -0,0 +1,83 @@
+package bitcoin
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chains returns stored blocks from lowest to tip and node hashes from 0 to tip that are the same up to and including
+// fork (none when fork is negative)
+func chains(lowest, tip uint64, fork int64) (map[uint64]string, []string) {
+	stored := map[uint64]string{}
+	node := make([]string, tip+1)
+
+	for h := uint64(0); h <= tip; h++ {
+		node[h] = fmt.Sprintf("node-%d", h)
+		if h < lowest {
+			continue
+		}
+
+		stored[h] = fmt.Sprintf("stored-%d", h)
+		if int64(h) <= fork {
+			stored[h] = node[h]
+		}
+	}
+
+	return stored, node
+}
+
+func TestCommonAncestor(t *testing.T) {
+	tests := []struct {
+		name     string
+		lowest   uint64
+		tip      uint64
+		fork     int64
+		expected uint64
+		chunks   int
+	}{
+		{"first chunk", 10, 40, 38, 38, 1},
+		{"across a chunk boundary", 10, 40, 35, 35, 2},
+		{"at the lowest block", 10, 40, 10, 10, 7},
+		{"at height 0", 0, 12, 0, 0, 3},
+		{"no ancestor", 10, 40, -1, 0, 7},
+	}
+
+	for _, test := range tests {
+		stored, node := chains(test.lowest, test.tip, test.fork)
+
+		chunks := 0
+		ancestor, err := commonAncestor(test.lowest, test.tip, 5, func(start, end uint64) (map[uint64]string, []string, error) {
+			chunks++
+			if start < test.lowest || end > test.tip || end-start >= 5 {
+				t.Errorf("%s: unexpected chunk %d-%d", test.name, start, end)
+			}
+			return stored, node[start : end+1], nil
+		})
+
+		if test.fork < 0 {
+			if err != ErrNoCommonAncestor {
+				t.Errorf("%s: expected ErrNoCommonAncestor, got %d, %v", test.name, ancestor, err)
+			}
+		} else if err != nil || ancestor != test.expected {
+			t.Errorf("%s: common ancestor is %d (%v), expected %d", test.name, ancestor, err, test.expected)
+		}
+
+		if chunks != test.chunks {
+			t.Errorf("%s: walked %d chunks, expected %d", test.name, chunks, test.chunks)
+		}
+	}
+}
+
+func TestCommonAncestorMissingBlock(t *testing.T) {
+	stored, node := chains(0, 20, 20)
+	delete(stored, 20)
+	delete(stored, 19)
+
+	ancestor, err := commonAncestor(0, 20, 5, func(start, end uint64) (map[uint64]string, []string, error) {
+		return stored, node[start : end+1], nil
+	})
+
+	if err != nil || ancestor != 18 {
+		t.Errorf("common ancestor is %d (%v), expected 18 before the missing blocks", ancestor, err)
+	}
+}
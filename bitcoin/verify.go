@@ -0,0 +1,338 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"forklol-collector/db"
+	"io"
+	"log"
+	"sort"
+)
+
+// number of stored blocks that are loaded from the database at once while verifying
+const verifyChunkSize = 1000
+
+// HeightRange is an inclusive range of block heights
+type HeightRange struct {
+	From uint64
+	To   uint64
+}
+
+// VerifyReport lists the problems ChainSync.Verify found in the stored chain of a coin
+type VerifyReport struct {
+	Coin    string
+	From    uint64
+	To      uint64
+	Checked uint64
+
+	Mismatches     []uint64
+	Missing        []uint64
+	MissingDetails []uint64
+	MissingRates   []uint64
+}
+
+// OK returns true when no problems were found
+func (r *VerifyReport) OK() bool {
+	return len(r.Mismatches) == 0 && len(r.Missing) == 0 && len(r.MissingDetails) == 0 && len(r.MissingRates) == 0
+}
+
+// BadRanges returns the height ranges with mismatching or missing blocks or hashrates. Blocks that only lack details
+// are not included.
+func (r *VerifyReport) BadRanges() []HeightRange {
+	bad := map[uint64]bool{}
+	for _, list := range [][]uint64{r.Mismatches, r.Missing, r.MissingRates} {
+		for _, h := range list {
+			bad[h] = true
+		}
+	}
+
+	ranges := make([]HeightRange, 0)
+	for h := r.From; h <= r.To; h++ {
+		if !bad[h] {
+			continue
+		}
+
+		if n := len(ranges); n > 0 && ranges[n-1].To == h-1 {
+			ranges[n-1].To = h
+		} else {
+			ranges = append(ranges, HeightRange{From: h, To: h})
+		}
+	}
+
+	return ranges
+}
+
+// Print writes a human readable version of the report to w
+func (r *VerifyReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "%s: verified %d blocks between %d and %d\n", r.Coin, r.Checked, r.From, r.To)
+
+	if r.OK() {
+		fmt.Fprintf(w, "✔ no problems found\n")
+		return
+	}
+
+	fmt.Fprintf(w, "✘ %d hash mismatches, %d missing blocks, %d blocks without details, %d blocks without hashrates\n",
+		len(r.Mismatches), len(r.Missing), len(r.MissingDetails), len(r.MissingRates))
+
+	for _, rng := range r.BadRanges() {
+		fmt.Fprintf(w, "  bad range %d - %d (%d blocks)\n", rng.From, rng.To, rng.To-rng.From+1)
+	}
+
+	if len(r.MissingDetails) > 0 {
+		fmt.Fprintf(w, "  missing details from %d to %d\n", r.MissingDetails[0], r.MissingDetails[len(r.MissingDetails)-1])
+	}
+}
+
+// Verify compares the stored blocks between from and to with the node. It reports blocks with a different hash,
+// heights without a stored block and blocks without details or hashrates rows.
+func (c *ChainSync) Verify(ctx context.Context, from, to uint64) (*VerifyReport, error) {
+	client := c.Coin.RPCClient()
+
+	nodeHeight, _, err := client.GetLastBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.TxLock.Lock()
+	err = c.loadCapabilities(ctx)
+	c.TxLock.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := VerifyReport{
+		Coin: c.Coin.Symbol,
+		From: from,
+		To:   to,
+	}
+
+	for start := from; start <= to; start += verifyChunkSize {
+		end := start + verifyChunkSize - 1
+		if end > to {
+			end = to
+		}
+
+		blocks, err := db.GetBlocksBetween(c.Coin.Symbol, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		stored := make(map[uint64]string, len(*blocks))
+		for _, blk := range *blocks {
+			stored[blk.Height] = blk.Hash
+		}
+
+		nodeHashes := []string{}
+		if start <= nodeHeight {
+			last := end
+			if last > nodeHeight {
+				last = nodeHeight
+			}
+
+			if nodeHashes, err = client.GetBlockHashes(ctx, start, last); err != nil {
+				return nil, err
+			}
+		}
+
+		for h := start; h <= end; h++ {
+			report.Checked++
+
+			hash, ok := stored[h]
+			if !ok {
+				report.Missing = append(report.Missing, h)
+				continue
+			}
+
+			// stored blocks above the node's tip are not part of its best chain
+			if h > nodeHeight {
+				report.Mismatches = append(report.Mismatches, h)
+				continue
+			}
+
+			if nodeHashes[h-start] != hash {
+				report.Mismatches = append(report.Mismatches, h)
+			}
+		}
+
+		log.Printf("Verified %s blocks up to %d\n", c.Coin.Symbol, end)
+	}
+
+	if report.MissingDetails, err = db.GetHeightsMissingDetails(c.Coin.Symbol, from, to); err != nil {
+		return nil, err
+	}
+
+	if report.MissingRates, err = db.GetHeightsMissingRates(c.Coin.Symbol, from, to); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// Repair fixes the problems of a report. Everything from the first hash mismatch on is rolled back and synced again,
+// the stored chain no longer follows the node's from there. Below it missing blocks are fetched and inserted in
+// place, the hashrates of the blocks whose windows they fall in are recomputed and missing details and hashrates
+// are collected.
+func (c *ChainSync) Repair(ctx context.Context, r *VerifyReport) error {
+	resync := r.To + 1
+	if len(r.Mismatches) > 0 {
+		resync = r.Mismatches[0]
+	}
+
+	c.TxLock.Lock()
+	resync, err := c.repairInPlace(ctx, r, resync)
+	c.TxLock.Unlock()
+	defer c.releaseWriterLock()
+
+	if err != nil {
+		return err
+	}
+
+	if resync > r.To {
+		return nil
+	}
+
+	rollback := uint64(0)
+	if resync > 0 {
+		rollback = resync - 1
+	}
+
+	log.Printf("Rolling back %s to block %d to re-sync the mismatching blocks\n", c.Coin.Symbol, rollback)
+
+	if err := c.rollback(rollback); err != nil {
+		return err
+	}
+
+	return c.Sync(ctx)
+}
+
+// rollback deletes the blocks after height, holding TxLock
+func (c *ChainSync) rollback(height uint64) error {
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	tx, err := db.GetDB().Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := db.DeleteBlocksAfter(tx, c.Coin.Symbol, height); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return c.commit(tx)
+}
+
+// repairInPlace repairs the problems of a report below height resync. It returns the height the chain has to be
+// synced again from, lower than resync when a missing block does not build on the stored block before it. The
+// caller must hold TxLock.
+func (c *ChainSync) repairInPlace(ctx context.Context, r *VerifyReport, resync uint64) (uint64, error) {
+	if err := c.ensureWriterLock(ctx); err != nil {
+		return resync, err
+	}
+
+	client := c.Coin.RPCClient()
+	inserted, collected := 0, 0
+
+	// heights whose hashrates are (re)computed
+	rates := map[uint64]bool{}
+	for _, h := range r.MissingRates {
+		rates[h] = true
+	}
+
+	for _, h := range r.Missing {
+		if h >= resync {
+			break
+		}
+
+		hash, err := client.GetBlockHash(ctx, h)
+		if err != nil {
+			return resync, err
+		}
+
+		header, err := client.GetBlockHeader(ctx, hash)
+		if err != nil {
+			return resync, err
+		}
+
+		if err := c.handleNewBlock(header, nil); err == db.ErrBrokenChain {
+			// the stored parent is not on the node's chain either
+			log.Printf("%s block %d does not build on the stored chain\n", c.Coin.Symbol, h)
+			return h - 1, nil
+		} else if err != nil {
+			return resync, fmt.Errorf("could not insert %s block %d: %s", c.Coin.Symbol, h, err.Error())
+		}
+
+		if err := c.collectDetails(ctx, h); err != nil {
+			return resync, fmt.Errorf("could not collect details of %s block %d: %s", c.Coin.Symbol, h, err.Error())
+		}
+		inserted++
+
+		// the windows of the blocks after it missed this block
+		later, err := db.GetHeightsBetweenTimes(c.Coin.Symbol, header.Time, header.Time+seedWindow)
+		if err != nil {
+			return resync, err
+		}
+
+		for _, l := range later {
+			if l > h {
+				rates[l] = true
+			}
+		}
+	}
+
+	heights := make([]uint64, 0, len(rates))
+	for h := range rates {
+		if h < resync {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, h := range heights {
+		if err := c.recomputeRates(h); err != nil {
+			return resync, fmt.Errorf("could not recompute hashrates of %s block %d: %s", c.Coin.Symbol, h, err.Error())
+		}
+	}
+
+	for _, h := range r.MissingDetails {
+		if h >= resync {
+			break
+		}
+
+		if err := c.collectDetails(ctx, h); err != nil {
+			return resync, fmt.Errorf("could not collect details of %s block %d: %s", c.Coin.Symbol, h, err.Error())
+		}
+		collected++
+	}
+
+	log.Printf("Repaired %s in place: %d missing blocks, %d hashrates, %d details\n", c.Coin.Symbol, inserted, len(heights), collected)
+
+	return resync, nil
+}
+
+// recomputeRates replaces the hashrates of a stored block. The caller must hold TxLock.
+func (c *ChainSync) recomputeRates(height uint64) error {
+	blk, err := db.GetBlock(c.Coin.Symbol, height)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.GetDB().Beginx()
+	if err != nil {
+		return err
+	}
+
+	rates, err := c.determineHashrates(tx, height, blk.Time)
+	if err == nil {
+		err = db.ReplaceRates(tx, c.Coin.Symbol, height, rates)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return c.commit(tx)
+}
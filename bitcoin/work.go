@@ -0,0 +1,97 @@
+package bitcoin
+
+import (
+	"context"
+	"fmt"
+	"forklol-collector/db"
+	"log"
+	"math/big"
+)
+
+// workScale converts chainwork (expected number of hashes) to the work column, which is kept in difficulty units:
+// a block of difficulty 1 takes 2^32 hashes
+var workScale = new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), 32))
+
+// parseChainWork parses the hex chainwork reported by the node
+func parseChainWork(hex string) (*big.Int, error) {
+	work, ok := new(big.Int).SetString(hex, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid chainwork %q", hex)
+	}
+
+	return work, nil
+}
+
+// workFromChainWork returns the (approximate) float work, only used for charting, of an exact chainwork
+func workFromChainWork(chainwork *big.Int) float64 {
+	work, _ := new(big.Float).Quo(new(big.Float).SetInt(chainwork), workScale).Float64()
+	return work
+}
+
+// RecomputeWork rebuilds the chainwork and work of the stored blocks between from and to from the headers of the
+// node. Blocks whose hash differs from the node's are left alone, it returns the number of blocks that changed.
+func (c *ChainSync) RecomputeWork(ctx context.Context, from, to uint64) (int64, error) {
+	if err := c.lockWriter(ctx); err != nil {
+		return 0, err
+	}
+	// releaseWriterLock takes TxLock itself, it has to run after TxLock is unlocked
+	defer c.releaseWriterLock()
+
+	c.TxLock.Lock()
+	defer c.TxLock.Unlock()
+
+	client := c.Coin.RPCClient()
+	updated := int64(0)
+
+	for start := from; start <= to; start += verifyChunkSize {
+		end := start + verifyChunkSize - 1
+		if end > to {
+			end = to
+		}
+
+		hashes, err := client.GetBlockHashes(ctx, start, end)
+		if err != nil {
+			return updated, err
+		}
+
+		headers, err := client.GetBlockHeaders(ctx, hashes)
+		if err != nil {
+			return updated, err
+		}
+
+		works := make([]db.BlockWork, 0, len(headers))
+		for _, header := range headers {
+			chainwork, err := parseChainWork(header.ChainWork)
+			if err != nil {
+				return updated, fmt.Errorf("%s block %d: %s", c.Coin.Symbol, header.Height, err.Error())
+			}
+
+			works = append(works, db.BlockWork{
+				Height:    header.Height,
+				Hash:      header.Hash,
+				ChainWork: chainwork.String(),
+				Work:      workFromChainWork(chainwork),
+			})
+		}
+
+		tx, err := db.GetDB().Beginx()
+		if err != nil {
+			return updated, err
+		}
+
+		n, err := db.UpdateWork(tx, c.Coin.Symbol, works)
+		if err != nil {
+			tx.Rollback()
+			return updated, err
+		}
+
+		if err := c.commit(tx); err != nil {
+			return updated, err
+		}
+		updated += n
+
+		log.Printf("Recomputed %s work up to block %d\n", c.Coin.Symbol, end)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,74 @@
+package bitcoin
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseChainWork(t *testing.T) {
+	tests := []struct {
+		hex   string
+		value string
+		ok    bool
+	}{
+		{"0000000000000000000000000000000000000000000000000000000100010001", "4295032833", true},
+		{"0", "0", true},
+		// more than fits into an uint64
+		{"000000000000000000000000000000000000000000789a2eefd07ba8b2b1e300", "145799208622792551670342400", true},
+		{"", "", false},
+		{"0x10", "", false},
+		{"zz", "", false},
+	}
+
+	for _, test := range tests {
+		work, err := parseChainWork(test.hex)
+		if (err == nil) != test.ok {
+			t.Errorf("parseChainWork(%q) returned error %v", test.hex, err)
+			continue
+		}
+
+		if test.ok && work.String() != test.value {
+			t.Errorf("parseChainWork(%q) is %s, expected %s", test.hex, work, test.value)
+		}
+	}
+}
+
+func TestWorkFromChainWork(t *testing.T) {
+	tests := []struct {
+		hex  string
+		work float64
+	}{
+		// the genesis block, 2^32 + 2^16 + 1 hashes
+		{"0000000000000000000000000000000000000000000000000000000100010001", 1.0000152590218967},
+		{"0000000000000000000000000000000000000000000000000000000100000000", 1},
+		{"0", 0},
+		{"000000000000000000000000000000000000000000789a2eefd07ba8b2b1e300", 3.394652358786961e+16},
+	}
+
+	for _, test := range tests {
+		chainwork, err := parseChainWork(test.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		work := workFromChainWork(chainwork)
+		if math.Abs(work-test.work) > test.work*1e-8 {
+			t.Errorf("workFromChainWork(%s) is %g, expected %g", test.hex, work, test.work)
+		}
+	}
+}
+
+func TestForkPointChainWork(t *testing.T) {
+	chainwork, err := parseChainWork("000000000000000000000000000000000000000000789a2eefd07ba8b2b1e301")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point := ForkPoint{Height: 10, ChainWork: chainwork}
+	fork := point.Fork("BTC", "TBTC")
+
+	// the float work cannot tell the last hash apart, the chainwork has to
+	if fork.ChainWork != "145799208622792551670342401" {
+		t.Errorf("fork chainwork is %s, expected 145799208622792551670342401", fork.ChainWork)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+// Difficulty algorithms of ChainParams
+const (
+	// Bitcoin's retarget every RetargetInterval blocks
+	DIFF_BITCOIN = "bitcoin"
+	// Bitcoin Cash emergency difficulty adjustment on top of Bitcoin's retarget (Aug - Nov 2017)
+	DIFF_EDA = "eda"
+	// Bitcoin Cash cw-144 per block adjustment (Nov 2017 - Nov 2020)
+	DIFF_CW144 = "cw-144"
+	// Bitcoin Cash aserti3-2d per block adjustment (Nov 2020)
+	DIFF_ASERT = "asert"
+	// signed blocks without proof of work (Liquid, Elements)
+	DIFF_NONE = "none"
+)
+
+// DifficultyEra is a change of the difficulty rules of a chain, from block Height on
+type DifficultyEra struct {
+	Height              uint64 `json:"height"`
+	RetargetInterval    int    `json:"retarget_interval"`
+	DifficultyAlgorithm string `json:"difficulty_algorithm"`
+}
+
+// ChainParams are the consensus parameters of a chain, used for hashrates, block subsidies and projections. Fields
+// of the chain_params option that are left out are taken from DEFAULT_CHAIN_PARAMS.
+type ChainParams struct {
+	// seconds between blocks
+	TargetSpacing int `json:"target_spacing"`
+	// blocks between two difficulty adjustments (1 for per block algorithms) and one of the DIFF_ constants, from
+	// the genesis block until the first of DifficultyEras
+	RetargetInterval    int    `json:"retarget_interval"`
+	DifficultyAlgorithm string `json:"difficulty_algorithm"`
+	// later changes of the difficulty rules, by height
+	DifficultyEras []DifficultyEra `json:"difficulty_eras"`
+	// subsidy of the first block in satoshis, halved every HalvingInterval blocks (never when 0)
+	InitialSubsidy  int64 `json:"initial_subsidy"`
+	HalvingInterval int   `json:"halving_interval"`
+	// proof of work hash, e.g. sha256d or scrypt
+	HashAlgorithm string `json:"hash_algorithm"`
+}
+
+// BITCOIN_CHAIN_PARAMS are the params of coins without defaults
+var BITCOIN_CHAIN_PARAMS = ChainParams{
+	TargetSpacing:       600,
+	RetargetInterval:    2016,
+	DifficultyAlgorithm: DIFF_BITCOIN,
+	InitialSubsidy:      50 * 1e8,
+	HalvingInterval:     210000,
+	HashAlgorithm:       "sha256d",
+}
+
+// DEFAULT_CHAIN_PARAMS has the params of known coins by symbol
+var DEFAULT_CHAIN_PARAMS = map[string]ChainParams{
+	"BTC":  BITCOIN_CHAIN_PARAMS,
+	"TBTC": BITCOIN_CHAIN_PARAMS,
+	"BCH": {
+		TargetSpacing:       600,
+		RetargetInterval:    2016,
+		DifficultyAlgorithm: DIFF_BITCOIN,
+		DifficultyEras: []DifficultyEra{
+			{Height: 478559, RetargetInterval: 2016, DifficultyAlgorithm: DIFF_EDA},
+			{Height: 504032, RetargetInterval: 1, DifficultyAlgorithm: DIFF_CW144},
+			{Height: 661648, RetargetInterval: 1, DifficultyAlgorithm: DIFF_ASERT},
+		},
+		InitialSubsidy:  50 * 1e8,
+		HalvingInterval: 210000,
+		HashAlgorithm:   "sha256d",
+	},
+	"LTC": {
+		TargetSpacing:       150,
+		RetargetInterval:    2016,
+		DifficultyAlgorithm: DIFF_BITCOIN,
+		InitialSubsidy:      50 * 1e8,
+		HalvingInterval:     840000,
+		HashAlgorithm:       "scrypt",
+	},
+	"ELM": {
+		TargetSpacing:       60,
+		RetargetInterval:    1,
+		DifficultyAlgorithm: DIFF_NONE,
+	},
+	"LQD": {
+		TargetSpacing:       60,
+		RetargetInterval:    1,
+		DifficultyAlgorithm: DIFF_NONE,
+	},
+}
+
+// Difficulty returns the difficulty algorithm and retarget interval of the block at height
+func (p ChainParams) Difficulty(height uint64) (string, int) {
+	algorithm, interval := p.DifficultyAlgorithm, p.RetargetInterval
+
+	for _, era := range p.DifficultyEras {
+		if height < era.Height {
+			break
+		}
+		algorithm, interval = era.DifficultyAlgorithm, era.RetargetInterval
+	}
+
+	return algorithm, interval
+}
+
+// NextRetarget returns the height of the first block after height that gets a new difficulty, 0 for chains without
+// proof of work
+func (p ChainParams) NextRetarget(height uint64) uint64 {
+	algorithm, interval := p.Difficulty(height + 1)
+	if algorithm == DIFF_NONE {
+		return 0
+	}
+
+	if interval <= 1 {
+		return height + 1
+	}
+
+	return (height/uint64(interval) + 1) * uint64(interval)
+}
+
+// Subsidy returns the subsidy (in satoshis) of the block at height according to the subsidy schedule
+func (p ChainParams) Subsidy(height uint64) int64 {
+	if p.HalvingInterval <= 0 {
+		return p.InitialSubsidy
+	}
+
+	halvings := height / uint64(p.HalvingInterval)
+	if halvings >= 64 {
+		return 0
+	}
+
+	return p.InitialSubsidy >> halvings
+}
+
+// NextHalving returns the height of the first block after height with a lower subsidy, 0 when it never halves
+func (p ChainParams) NextHalving(height uint64) uint64 {
+	if p.HalvingInterval <= 0 || p.Subsidy(height) == 0 {
+		return 0
+	}
+
+	return (height/uint64(p.HalvingInterval) + 1) * uint64(p.HalvingInterval)
+}
+
+// Supply returns the satoshis created by the subsidies of the blocks up to and including height
+func (p ChainParams) Supply(height uint64) int64 {
+	if p.HalvingInterval <= 0 {
+		return int64(height+1) * p.InitialSubsidy
+	}
+
+	supply, interval := int64(0), uint64(p.HalvingInterval)
+	for start := uint64(0); start <= height; start += interval {
+		subsidy := p.Subsidy(start)
+		if subsidy == 0 {
+			break
+		}
+
+		end := start + interval - 1
+		if end > height {
+			end = height
+		}
+		supply += int64(end-start+1) * subsidy
+	}
+
+	return supply
+}
+
+// chainParams returns the params of a coin: the defaults of its symbol with the set fields of overrides on top
+func chainParams(symbol string, overrides *ChainParams) ChainParams {
+	params, ok := DEFAULT_CHAIN_PARAMS[symbol]
+	if !ok {
+		params = BITCOIN_CHAIN_PARAMS
+	}
+
+	if overrides == nil {
+		return params
+	}
+
+	if overrides.TargetSpacing > 0 {
+		params.TargetSpacing = overrides.TargetSpacing
+	}
+	if overrides.RetargetInterval > 0 {
+		params.RetargetInterval = overrides.RetargetInterval
+	}
+	if overrides.DifficultyAlgorithm != "" {
+		params.DifficultyAlgorithm = overrides.DifficultyAlgorithm
+	}
+	if overrides.DifficultyEras != nil {
+		params.DifficultyEras = overrides.DifficultyEras
+	}
+	if overrides.InitialSubsidy > 0 {
+		params.InitialSubsidy = overrides.InitialSubsidy
+	}
+	if overrides.HalvingInterval > 0 {
+		params.HalvingInterval = overrides.HalvingInterval
+	}
+	if overrides.HashAlgorithm != "" {
+		params.HashAlgorithm = overrides.HashAlgorithm
+	}
+
+	return params
+}
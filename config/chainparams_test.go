@@ -0,0 +1,115 @@
+package config
+
+import "testing"
+
+func TestSubsidy(t *testing.T) {
+	ltc := DEFAULT_CHAIN_PARAMS["LTC"]
+
+	tests := []struct {
+		params   ChainParams
+		height   uint64
+		expected int64
+	}{
+		{BITCOIN_CHAIN_PARAMS, 0, 5000000000},
+		{BITCOIN_CHAIN_PARAMS, 209999, 5000000000},
+		{BITCOIN_CHAIN_PARAMS, 210000, 2500000000},
+		{BITCOIN_CHAIN_PARAMS, 420000, 1250000000},
+		{BITCOIN_CHAIN_PARAMS, 840000, 312500000},
+		{BITCOIN_CHAIN_PARAMS, 64 * 210000, 0},
+		{ltc, 840000, 2500000000},
+		{ChainParams{InitialSubsidy: 100}, 1000000, 100},
+		{DEFAULT_CHAIN_PARAMS["LQD"], 10, 0},
+	}
+
+	for _, test := range tests {
+		if subsidy := test.params.Subsidy(test.height); subsidy != test.expected {
+			t.Errorf("subsidy at %d with %+v is %d, expected %d", test.height, test.params, subsidy, test.expected)
+		}
+	}
+}
+
+func TestSupply(t *testing.T) {
+	tests := []struct {
+		params   ChainParams
+		height   uint64
+		expected int64
+	}{
+		{BITCOIN_CHAIN_PARAMS, 0, 5000000000},
+		{BITCOIN_CHAIN_PARAMS, 209999, 210000 * 5000000000},
+		{BITCOIN_CHAIN_PARAMS, 210000, 210000*5000000000 + 2500000000},
+		{BITCOIN_CHAIN_PARAMS, 100 * 210000, 2099999997690000},
+		{ChainParams{InitialSubsidy: 100}, 9, 1000},
+		{DEFAULT_CHAIN_PARAMS["LQD"], 1000, 0},
+	}
+
+	for _, test := range tests {
+		if supply := test.params.Supply(test.height); supply != test.expected {
+			t.Errorf("supply at %d with %+v is %d, expected %d", test.height, test.params, supply, test.expected)
+		}
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	bch := DEFAULT_CHAIN_PARAMS["BCH"]
+
+	tests := []struct {
+		name      string
+		params    ChainParams
+		height    uint64
+		algorithm string
+		interval  int
+		retarget  uint64
+	}{
+		{"bitcoin", BITCOIN_CHAIN_PARAMS, 2015, DIFF_BITCOIN, 2016, 2016},
+		{"bitcoin on retarget", BITCOIN_CHAIN_PARAMS, 2016, DIFF_BITCOIN, 2016, 4032},
+		{"bch before the fork", bch, 478558, DIFF_BITCOIN, 2016, 479808},
+		{"bch eda", bch, 478559, DIFF_EDA, 2016, 479808},
+		{"bch eda to cw-144", bch, 504031, DIFF_EDA, 2016, 504032},
+		{"bch cw-144", bch, 504032, DIFF_CW144, 1, 504033},
+		{"bch asert", bch, 700000, DIFF_ASERT, 1, 700001},
+		{"liquid", DEFAULT_CHAIN_PARAMS["LQD"], 100, DIFF_NONE, 1, 0},
+	}
+
+	for _, test := range tests {
+		algorithm, interval := test.params.Difficulty(test.height)
+		if algorithm != test.algorithm || interval != test.interval {
+			t.Errorf("%s: difficulty is %s every %d blocks, expected %s every %d", test.name, algorithm, interval, test.algorithm, test.interval)
+		}
+
+		if retarget := test.params.NextRetarget(test.height); retarget != test.retarget {
+			t.Errorf("%s: next retarget at %d, expected %d", test.name, retarget, test.retarget)
+		}
+	}
+}
+
+func TestNextHalving(t *testing.T) {
+	tests := []struct {
+		params   ChainParams
+		height   uint64
+		expected uint64
+	}{
+		{BITCOIN_CHAIN_PARAMS, 0, 210000},
+		{BITCOIN_CHAIN_PARAMS, 840000, 1050000},
+		{BITCOIN_CHAIN_PARAMS, 64 * 210000, 0},
+		{DEFAULT_CHAIN_PARAMS["LTC"], 840000, 1680000},
+		{DEFAULT_CHAIN_PARAMS["ELM"], 10, 0},
+	}
+
+	for _, test := range tests {
+		if halving := test.params.NextHalving(test.height); halving != test.expected {
+			t.Errorf("next halving after %d with %+v is %d, expected %d", test.height, test.params, halving, test.expected)
+		}
+	}
+}
+
+func TestChainParamsOverrides(t *testing.T) {
+	params := chainParams("BCH", &ChainParams{TargetSpacing: 300, HashAlgorithm: "sha256"})
+
+	if params.TargetSpacing != 300 || params.HashAlgorithm != "sha256" || len(params.DifficultyEras) != 3 || params.HalvingInterval != 210000 {
+		t.Errorf("overrides are not merged into the BCH defaults: %+v", params)
+	}
+
+	if params := chainParams("XYZ", nil); params.DifficultyAlgorithm != DIFF_BITCOIN || params.RetargetInterval != 2016 {
+		t.Errorf("unknown coins do not get Bitcoin's params: %+v", params)
+	}
+}
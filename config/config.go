@@ -1,13 +1,23 @@
 package config
 
+import (
+	"fmt"
+	"sync"
+)
+
 const (
-	CHAINSPLIT_TIMESTAMP = 1501593374
-	CHAINSPLIT_WORK = 32729585000856628.00
-	CHAINSPLIT_HEIGHT = 478558
+	DEFAULT_FETCH_WORKERS = 4
+	DEFAULT_RPC_TIMEOUT   = 30
+	DEFAULT_RPC_RETRIES   = 5
+
+	// seconds between polls of coins without and with zmq notifications
+	DEFAULT_POLL_INTERVAL = 5
+	ZMQ_POLL_INTERVAL     = 60
 )
 
 type options struct {
 	DEBUG                bool
+	CONFIG_FILE          string
 	DB_CONNECTION_STRING string
 	BTCAVG_PUBKEY        string
 	BTCAVG_SECRET        string
@@ -17,6 +27,11 @@ type options struct {
 	RPC_TBTC string
 	RPC_ELM  string
 	RPC_LQD  string
+
+	// the coins and the fork registry are swapped on a reload while syncers read them, see Configure
+	mu    sync.RWMutex
+	coins []CoinOptions
+	forks []Fork
 }
 
 var opts options
@@ -24,3 +39,145 @@ var opts options
 func Options() *options {
 	return &opts
 }
+
+// Configure validates the fork registry and the coins to collect and replaces both at once. The current ones are
+// kept when either is invalid.
+func (o *options) Configure(coins []CoinOptions, forks []Fork) error {
+	enabled, registry, err := o.Validate(coins, forks)
+	if err != nil {
+		return err
+	}
+
+	o.Store(enabled, registry)
+
+	return nil
+}
+
+// Validate returns the enabled coins with their defaults and the fork registry of a config file, without storing them
+func (o *options) Validate(coins []CoinOptions, forks []Fork) ([]CoinOptions, []Fork, error) {
+	registry, err := forkRegistry(forks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enabled, err := o.coinOptions(coins, registry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return enabled, registry, nil
+}
+
+// Store replaces the coins and the fork registry with ones returned by Validate
+func (o *options) Store(coins []CoinOptions, forks []Fork) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.coins, o.forks = coins, forks
+}
+
+// Coins returns the enabled coins
+func (o *options) Coins() []CoinOptions {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.coins
+}
+
+// coinOptions validates the given coins, applies the RPC_* url overrides and defaults and returns the enabled ones.
+// The rpc_url shorthand of every coin is moved into its Endpoints.
+func (o *options) coinOptions(coins []CoinOptions, forks []Fork) ([]CoinOptions, error) {
+	enabled := make([]CoinOptions, 0, len(coins))
+
+	for _, coin := range coins {
+		if url := o.rpcOverride(coin.Symbol); url != "" {
+			coin.RPCUrl = url
+		}
+
+		endpoints := make([]EndpointOptions, 0, len(coin.Endpoints)+1)
+		if coin.RPCUrl != "" {
+			endpoints = append(endpoints, EndpointOptions{
+				URL:        coin.RPCUrl,
+				User:       coin.RPCUser,
+				Pass:       coin.RPCPass,
+				CookieFile: coin.RPCCookieFile,
+			})
+		}
+
+		for _, ep := range coin.Endpoints {
+			if ep.URL == "" {
+				return nil, fmt.Errorf("coin %s has an endpoint without url", coin.Symbol)
+			}
+
+			if (ep.TLSCert == "") != (ep.TLSKey == "") {
+				return nil, fmt.Errorf("coin %s endpoint %s needs both tls_cert and tls_key", coin.Symbol, ep.URL)
+			}
+			endpoints = append(endpoints, ep)
+		}
+
+		if len(endpoints) == 0 {
+			return nil, fmt.Errorf("coin %s has no rpc url or endpoints", coin.Symbol)
+		}
+
+		coin.Endpoints = endpoints
+		coin.RPCUrl, coin.RPCUser, coin.RPCPass, coin.RPCCookieFile = "", "", "", ""
+
+		if coin.FetchWorkers <= 0 {
+			coin.FetchWorkers = DEFAULT_FETCH_WORKERS
+		}
+
+		if coin.PollInterval <= 0 {
+			coin.PollInterval = DEFAULT_POLL_INTERVAL
+			if coin.ZMQUrl != "" {
+				coin.PollInterval = ZMQ_POLL_INTERVAL
+			}
+		}
+
+		if coin.RPCTimeout <= 0 {
+			coin.RPCTimeout = DEFAULT_RPC_TIMEOUT
+		}
+
+		if coin.RPCRetries == nil {
+			retries := DEFAULT_RPC_RETRIES
+			coin.RPCRetries = &retries
+		}
+
+		params := chainParams(coin.Symbol, coin.ChainParams)
+		coin.ChainParams = &params
+
+		// coins of a fork start at their earliest split
+		if coin.StartHeight == nil {
+			start := uint64(0)
+			for n, fork := range forksWith(forks, coin.Symbol) {
+				if n == 0 || fork.Height < start {
+					start = fork.Height
+				}
+			}
+			coin.StartHeight = &start
+		}
+
+		if !coin.Disabled {
+			enabled = append(enabled, coin)
+		}
+	}
+
+	return enabled, nil
+}
+
+// rpcOverride returns the rpc url that was set through env vars or flags for the given coin symbol
+func (o *options) rpcOverride(symbol string) string {
+	switch symbol {
+	case "BTC":
+		return o.RPC_BTC
+	case "BCH":
+		return o.RPC_BCH
+	case "TBTC":
+		return o.RPC_TBTC
+	case "ELM":
+		return o.RPC_ELM
+	case "LQD":
+		return o.RPC_LQD
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package config
+
+import "testing"
+
+func TestConfigure(t *testing.T) {
+	var o options
+
+	coins := []CoinOptions{
+		{Symbol: "BTC", RPCUrl: "http://127.0.0.1:8332"},
+		{Symbol: "BCH", RPCUrl: "http://127.0.0.1:9332"},
+		{Symbol: "LTC", RPCUrl: "http://127.0.0.1:9432", Disabled: true},
+	}
+
+	if err := o.Configure(coins, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(o.Coins()); n != 2 {
+		t.Fatalf("%d coins enabled, expected 2", n)
+	}
+
+	bch := o.Coins()[1]
+	if len(bch.Endpoints) != 1 || bch.RPCUrl != "" || *bch.StartHeight != DEFAULT_FORKS[0].Height || bch.ChainParams.TargetSpacing != 600 {
+		t.Errorf("BCH options are not defaulted: %+v", bch)
+	}
+
+	if _, ok := o.ForkOf("BCH"); !ok {
+		t.Error("expected the default fork of BCH")
+	}
+
+	tests := []struct {
+		name  string
+		coins []CoinOptions
+		forks []Fork
+	}{
+		{"invalid fork", []CoinOptions{{Symbol: "BTC", RPCUrl: "http://127.0.0.1:8332"}}, []Fork{{Parent: "BTC", Child: "BTC"}}},
+		{"invalid coin", []CoinOptions{{Symbol: "BTC"}}, []Fork{{Parent: "BTC", Child: "BSV"}}},
+		{"hex chainwork", []CoinOptions{{Symbol: "BTC", RPCUrl: "http://127.0.0.1:8332"}}, []Fork{{Parent: "BTC", Child: "BCH", ChainWork: "00a4b8"}}},
+	}
+
+	// neither the coins nor the forks change when one of them is invalid
+	for _, test := range tests {
+		if err := o.Configure(test.coins, test.forks); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		if len(o.Coins()) != 2 {
+			t.Errorf("%s: coins were replaced", test.name)
+		}
+
+		if _, ok := o.ForkOf("BSV"); ok {
+			t.Errorf("%s: forks were replaced", test.name)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// EndpointOptions describes one of the rpc endpoints of a coin. Endpoints with a lower priority are preferred. When
+// a cookie file is set it is used instead of user and pass.
+type EndpointOptions struct {
+	URL        string `json:"url"`
+	User       string `json:"user"`
+	Pass       string `json:"pass"`
+	CookieFile string `json:"cookie_file"`
+	Priority   int    `json:"priority"`
+
+	// pem files for nodes behind tls, all optional
+	TLSCA         string `json:"tls_ca"`
+	TLSCert       string `json:"tls_cert"`
+	TLSKey        string `json:"tls_key"`
+	TLSServerName string `json:"tls_server_name"`
+}
+
+// UsesTLS returns true when any of the tls options is set
+func (e EndpointOptions) UsesTLS() bool {
+	return e.TLSCA != "" || e.TLSCert != "" || e.TLSKey != "" || e.TLSServerName != ""
+}
+
+// CoinOptions describes a single coin (chain) that should be collected. RPCUrl, RPCUser, RPCPass and RPCCookieFile
+// are a shorthand for a single endpoint with priority 0.
+type CoinOptions struct {
+	Symbol        string `json:"symbol"`
+	RPCUrl        string `json:"rpc_url"`
+	RPCUser       string `json:"rpc_user"`
+	RPCPass       string `json:"rpc_pass"`
+	RPCCookieFile string `json:"rpc_cookie_file"`
+	Disabled      bool   `json:"disabled"`
+
+	// force getblockstats and segwit details on or off, both are detected from the node when left out
+	RPCStats *bool `json:"rpc_stats"`
+	SegWit   *bool `json:"segwit"`
+
+	Endpoints []EndpointOptions `json:"endpoints"`
+
+	// bitcoind -zmqpubhashblock endpoint (tcp://host:port), new blocks are synced as soon as they are announced
+	ZMQUrl string `json:"zmq_url"`
+
+	// seconds between two syncs when no blocks are announced, defaults to 5 (60 with a zmq_url)
+	PollInterval int `json:"poll_interval"`
+
+	// number of workers that prefetch blocks while syncing
+	FetchWorkers int `json:"fetch_workers"`
+
+	// sync block headers first and collect the details of the blocks in a second pass
+	HeaderSync bool `json:"header_sync"`
+
+	// consensus params, the defaults of the symbol are used for the ones that are left out
+	ChainParams *ChainParams `json:"chain_params"`
+
+	// first block to collect into a fresh database, defaults to the earliest split of the forks the coin is part of
+	// and 0 for other coins
+	StartHeight *uint64 `json:"start_height"`
+
+	// timeout of a single rpc call in seconds and number of retries after transient rpc errors
+	RPCTimeout int  `json:"rpc_timeout"`
+	RPCRetries *int `json:"rpc_retries"`
+}
+
+// File is the layout of the json config file. Values set through env vars or flags take precedence.
+type File struct {
+	DBUser   string `json:"db_user"`
+	DBPass   string `json:"db_pass"`
+	DBHost   string `json:"db_host"`
+	DBPort   string `json:"db_port"`
+	DBScheme string `json:"db_scheme"`
+
+	BTCAvgPubkey string `json:"btcavg_pubkey"`
+	BTCAvgSecret string `json:"btcavg_secret"`
+
+	Coins []CoinOptions `json:"coins"`
+
+	// fork registry, defaults to DEFAULT_FORKS
+	Forks []Fork `json:"forks"`
+}
+
+// ReadFile reads and validates the json config file at the given path
+func ReadFile(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := File{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("could not decode config file %s: %s", path, err.Error())
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %s", path, err.Error())
+	}
+
+	return &f, nil
+}
+
+// SaveFork adds a fork to the registry in the config file at the given path, replacing the fork of the same child.
+// A file without forks gets DEFAULT_FORKS first. Only the forks array is rewritten, the rest of the file is kept as
+// it is.
+func SaveFork(path string, fork Fork) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("could not decode config file %s: %s", path, err.Error())
+	}
+
+	forks := []Fork{}
+	if f, ok := raw["forks"]; ok {
+		if err := json.Unmarshal(f, &forks); err != nil {
+			return fmt.Errorf("could not decode forks in config file %s: %s", path, err.Error())
+		}
+	}
+
+	if len(forks) == 0 {
+		forks = append(forks, DEFAULT_FORKS...)
+	}
+
+	replaced := false
+	for n := range forks {
+		if strings.ToUpper(forks[n].Child) == fork.Child {
+			forks[n], replaced = fork, true
+		}
+	}
+
+	if !replaced {
+		forks = append(forks, fork)
+	}
+
+	value, err := json.MarshalIndent(forks, "\t", "\t")
+	if err != nil {
+		return err
+	}
+
+	if data, err = replaceKey(data, "forks", value); err != nil {
+		return fmt.Errorf("could not update config file %s: %s", path, err.Error())
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// replaceKey replaces the value of a top level key of the json object in data, a missing key is added at the end
+func replaceKey(data []byte, key string, value []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, errors.New("not a json object")
+	}
+
+	empty := true
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		// the offset is right behind the key, the value follows after a colon
+		start := dec.InputOffset()
+
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		if t == key {
+			replaced := append([]byte{}, data[:start]...)
+			replaced = append(replaced, ": "...)
+			replaced = append(replaced, value...)
+			return append(replaced, data[dec.InputOffset():]...), nil
+		}
+
+		empty = false
+	}
+
+	// the closing brace of the object
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	end := int(dec.InputOffset()) - 1
+
+	added := append([]byte{}, bytes.TrimRight(data[:end], " \t\r\n")...)
+	if !empty {
+		added = append(added, ',')
+	}
+	added = append(added, fmt.Sprintf("\n\t%q: ", key)...)
+	added = append(added, value...)
+	added = append(added, '\n')
+
+	return append(added, data[end:]...), nil
+}
+
+// validate checks that every coin has a unique symbol
+func (f *File) validate() error {
+	seen := map[string]bool{}
+
+	for n, coin := range f.Coins {
+		if coin.Symbol == "" {
+			return fmt.Errorf("coin #%d has no symbol", n+1)
+		}
+
+		sym := strings.ToUpper(coin.Symbol)
+		if seen[sym] {
+			return fmt.Errorf("coin %s is defined more than once", sym)
+		}
+		seen[sym] = true
+
+		f.Coins[n].Symbol = sym
+	}
+
+	return nil
+}
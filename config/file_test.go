@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			"replaced",
+			"{\n\t\"coins\": [],\n\t\"forks\": [1, 2],\n\t\"zmq\": true\n}\n",
+			"{\n\t\"coins\": [],\n\t\"forks\": [3],\n\t\"zmq\": true\n}\n",
+		},
+		{
+			"added",
+			"{\n\t\"zmq\": true,\n\t\"coins\": []\n}\n",
+			"{\n\t\"zmq\": true,\n\t\"coins\": [],\n\t\"forks\": [3]\n}\n",
+		},
+		{
+			"empty object",
+			"{}",
+			"{\n\t\"forks\": [3]\n}",
+		},
+		{
+			"nested key is left alone",
+			"{\"coins\": [{\"forks\": 1}], \"forks\":[]}",
+			"{\"coins\": [{\"forks\": 1}], \"forks\": [3]}",
+		},
+	}
+
+	for _, test := range tests {
+		data, err := replaceKey([]byte(test.data), "forks", []byte("[3]"))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if string(data) != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, data, test.expected)
+		}
+	}
+
+	if _, err := replaceKey([]byte("[]"), "forks", []byte("[3]")); err == nil {
+		t.Error("expected an array to be refused")
+	}
+}
+
+func TestSaveFork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forklol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	config := "{\n\t\"zmq_url\": \"tcp://127.0.0.1:28332\",\n\t\"coins\": [],\n\t\"forks\": [{\"parent\": \"BTC\", \"child\": \"BCH\", \"height\": 1}]\n}\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveFork(path, Fork{Parent: "BTC", Child: "BCH", Height: 478558, ChainWork: "140572877569874404918198528"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFork(path, Fork{Parent: "BTC", Child: "TBTC", Height: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "{\n\t\"zmq_url\": \"tcp://127.0.0.1:28332\",\n\t\"coins\": [],\n\t\"forks\": "
+	if string(data[:len(prefix)]) != prefix {
+		t.Errorf("the rest of the file changed: %s", data)
+	}
+
+	f := struct {
+		Forks []Fork `json:"forks"`
+	}{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Forks) != 2 || f.Forks[0].Height != 478558 || f.Forks[0].ChainWork != "140572877569874404918198528" || f.Forks[1].Child != "TBTC" {
+		t.Errorf("unexpected forks %+v", f.Forks)
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Fork is a chain split in the fork registry: Child split off Parent after block Height, the last common block.
+// Time, ChainWork (exact and decimal, like the chainwork column, empty when unknown) and Work (in difficulty units,
+// like the work column) are those of that block.
+type Fork struct {
+	Parent    string  `json:"parent"`
+	Child     string  `json:"child"`
+	Height    uint64  `json:"height"`
+	Time      uint64  `json:"time"`
+	ChainWork string  `json:"chainwork,omitempty"`
+	Work      float64 `json:"work"`
+}
+
+// DEFAULT_FORKS is the fork registry when the config file has no forks
+var DEFAULT_FORKS = []Fork{
+	{Parent: "BTC", Child: "BCH", Height: 478558, Time: 1501593374, Work: 32729585000856628.00},
+}
+
+// forkRegistry validates the forks of the config file, DEFAULT_FORKS is used when there are none
+func forkRegistry(forks []Fork) ([]Fork, error) {
+	if len(forks) == 0 {
+		return DEFAULT_FORKS, nil
+	}
+
+	children := map[string]bool{}
+	registry := make([]Fork, 0, len(forks))
+
+	for n, fork := range forks {
+		fork.Parent, fork.Child = strings.ToUpper(fork.Parent), strings.ToUpper(fork.Child)
+
+		if fork.Parent == "" || fork.Child == "" {
+			return nil, fmt.Errorf("fork #%d needs a parent and a child", n+1)
+		}
+
+		if fork.Parent == fork.Child {
+			return nil, fmt.Errorf("fork #%d has %s as both parent and child", n+1, fork.Parent)
+		}
+
+		if _, ok := new(big.Int).SetString(fork.ChainWork, 10); fork.ChainWork != "" && !ok {
+			return nil, fmt.Errorf("fork #%d has an invalid chainwork %q, expected a decimal number", n+1, fork.ChainWork)
+		}
+
+		if children[fork.Child] {
+			return nil, fmt.Errorf("coin %s is the child of more than one fork", fork.Child)
+		}
+		children[fork.Child] = true
+
+		registry = append(registry, fork)
+	}
+
+	return registry, nil
+}
+
+// ForkOf returns the fork a coin split off from its parent in
+func (o *options) ForkOf(child string) (Fork, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	for _, fork := range o.forks {
+		if fork.Child == child {
+			return fork, true
+		}
+	}
+
+	return Fork{}, false
+}
+
+// forksWith returns the forks of the registry a coin is the parent or the child of
+func forksWith(registry []Fork, symbol string) []Fork {
+	forks := make([]Fork, 0)
+	for _, fork := range registry {
+		if fork.Parent == symbol || fork.Child == symbol {
+			forks = append(forks, fork)
+		}
+	}
+
+	return forks
+}
@@ -21,3 +21,8 @@ func GetDB() *sqlx.DB {
 	return conn
 }
 
+// CloseDB closes the connection pool opened by InitDB, waiting for running queries to finish
+func CloseDB() error {
+	return conn.Close()
+}
+
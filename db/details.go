@@ -0,0 +1,83 @@
+package db
+
+import (
+	"github.com/jmoiron/sqlx"
+	"strings"
+)
+
+// Details is a row of the details table, the canonical form of the block stats of every node implementation (see
+// bitcoin.StatsMapper). Nil values are stored as NULL. The v-prefixed feerates are per byte (the _old feerates of
+// the original collector), the others per virtual byte and like the other segwit fields only set for blocks after
+// segwit activated.
+type Details struct {
+	Coin       string `db:"coin"`
+	Height     uint64 `db:"height"`
+	Time       *int64 `db:"time"`
+	MedianTime *int64 `db:"mediantime"`
+
+	Txs              *int64 `db:"txs"`
+	Inputs           *int64 `db:"inputs"`
+	Outputs          *int64 `db:"outputs"`
+	TotalOut         *int64 `db:"totalout"`
+	TotalSize        *int64 `db:"totalsize"`
+	UTXOIncrease     *int64 `db:"utxoinc"`
+	UTXOSizeIncrease *int64 `db:"utxosizeinc"`
+
+	Reward  *int64 `db:"reward"`
+	Subsidy *int64 `db:"subsidy"`
+	Fee     *int64 `db:"fee"`
+
+	AvgFee    *int64 `db:"avgfee"`
+	MinFee    *int64 `db:"minfee"`
+	MaxFee    *int64 `db:"maxfee"`
+	MedianFee *int64 `db:"medianfee"`
+
+	VAvgFeeRate    *int64 `db:"vavgfeerate"`
+	VMinFeeRate    *int64 `db:"vminfeerate"`
+	VMaxFeeRate    *int64 `db:"vmaxfeerate"`
+	VMedianFeeRate *int64 `db:"vmedianfeerate"`
+
+	AvgFeeRate        *int64 `db:"avgfeerate"`
+	MinFeeRate        *int64 `db:"minfeerate"`
+	MaxFeeRate        *int64 `db:"maxfeerate"`
+	MedianFeeRate     *int64 `db:"medianfeerate"`
+	SegWitTxs         *int64 `db:"swtxs"`
+	SegWitTotalSize   *int64 `db:"swtotalsize"`
+	SegWitTotalWeight *int64 `db:"swtotalweight"`
+	TotalWeight       *int64 `db:"totalweight"`
+
+	// feerate percentiles by weight
+	FeeRateP10 *int64 `db:"feerate_p10"`
+	FeeRateP25 *int64 `db:"feerate_p25"`
+	FeeRateP50 *int64 `db:"feerate_p50"`
+	FeeRateP75 *int64 `db:"feerate_p75"`
+	FeeRateP90 *int64 `db:"feerate_p90"`
+}
+
+// detailsLayout has the fields of Details in the column order of the details table: the 30 columns of the original
+// fork.lol table (see details.sql) followed by the feerate percentiles of migrations.sql. Only some of the original
+// column names are known, so rows are inserted by position and the db tags of Details are just bind names.
+var detailsLayout = []string{
+	"coin", "height", "avgfee", "avgfeerate", "vavgfeerate", "inputs", "outputs",
+	"maxfee", "maxfeerate", "vmaxfeerate", "medianfee", "medianfeerate", "vmedianfeerate",
+	"time", "mediantime", "minfee", "minfeerate", "vminfeerate", "reward", "subsidy", "fee", "totalsize", "txs",
+	"swtotalsize", "swtotalweight", "swtxs", "totalout", "totalweight", "utxoinc", "utxosizeinc",
+	"feerate_p10", "feerate_p25", "feerate_p50", "feerate_p75", "feerate_p90",
+}
+
+// InsertDetails will insert the block related statistics of a single block
+func InsertDetails(tx *sqlx.Tx, details *Details) (int64, error) {
+	qry := "INSERT INTO details VALUES(:" + strings.Join(detailsLayout, ", :") + ")"
+
+	r, err := tx.NamedExec(qry, details)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
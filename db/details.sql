@@ -0,0 +1,36 @@
+-- The details table of the original fork.lol database, for setting up a fresh database before migrations.sql.
+-- Rows are inserted by position (see detailsLayout in details.go), so existing tables only need the same column
+-- order. The names of the columns the original collector inserted by name are kept, the segwit ones are descriptive.
+CREATE TABLE IF NOT EXISTS details (
+  coin           VARCHAR(8)      NOT NULL,
+  height         INT UNSIGNED    NOT NULL,
+  avgfee         BIGINT          NULL,
+  avgfeerate     BIGINT          NULL,
+  vavgfeerate    BIGINT          NULL,
+  inputs         BIGINT          NULL,
+  outputs        BIGINT          NULL,
+  maxfee         BIGINT          NULL,
+  maxfeerate     BIGINT          NULL,
+  vmaxfeerate    BIGINT          NULL,
+  medianfee      BIGINT          NULL,
+  medianfeerate  BIGINT          NULL,
+  vmedianfeerate BIGINT          NULL,
+  time           BIGINT          NULL,
+  mediantime     BIGINT          NULL,
+  minfee         BIGINT          NULL,
+  minfeerate     BIGINT          NULL,
+  vminfeerate    BIGINT          NULL,
+  reward         BIGINT          NULL,
+  subsidy        BIGINT          NULL,
+  fee            BIGINT          NULL,
+  totalsize      BIGINT          NULL,
+  txs            BIGINT          NULL,
+  swtotalsize    BIGINT          NULL,
+  swtotalweight  BIGINT          NULL,
+  swtxs          BIGINT          NULL,
+  totalout       BIGINT          NULL,
+  totalweight    BIGINT          NULL,
+  utxoinc        BIGINT          NULL,
+  utxosizeinc    BIGINT          NULL,
+  PRIMARY KEY (coin, height)
+);
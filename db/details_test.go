@@ -0,0 +1,46 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+// every field of Details has to be bound exactly once, in the column order of the table
+func TestDetailsLayout(t *testing.T) {
+	tags := map[string]bool{}
+	typ := reflect.TypeOf(Details{})
+	for n := 0; n < typ.NumField(); n++ {
+		tags[typ.Field(n).Tag.Get("db")] = true
+	}
+
+	if len(detailsLayout) != 35 {
+		t.Errorf("details has 30 original and 5 percentile columns, the layout has %d", len(detailsLayout))
+	}
+
+	seen := map[string]bool{}
+	for _, name := range detailsLayout {
+		if !tags[name] {
+			t.Errorf("%s is not a field of Details", name)
+		}
+
+		if seen[name] {
+			t.Errorf("%s is bound twice", name)
+		}
+		seen[name] = true
+	}
+
+	for tag := range tags {
+		if !seen[tag] {
+			t.Errorf("field %s is not inserted", tag)
+		}
+	}
+
+	// the columns the original collector inserted by name
+	positions := map[string]int{"coin": 0, "height": 1, "avgfee": 2, "vavgfeerate": 4, "inputs": 5, "outputs": 6,
+		"time": 13, "mediantime": 14, "subsidy": 19, "fee": 20, "txs": 22, "utxosizeinc": 29, "feerate_p10": 30}
+	for name, pos := range positions {
+		if detailsLayout[pos] != name {
+			t.Errorf("column %d is %s, expected %s", pos, detailsLayout[pos], name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrLockLost is returned by WriterLock.Check when the lock is no longer held, e.g. after the connection dropped
+var ErrLockLost = errors.New("writer lock lost")
+
+// WriterLock is a named MySQL lock (GET_LOCK) that makes sure only one collector writes the blocks of a coin. It is
+// held by a dedicated connection, so MySQL releases it as soon as the owning collector dies and a standby instance
+// can take over. Lock names are global to the MySQL server, so the name is prefixed with the schema (DATABASE()) in
+// every query and collectors of different schemas on one server do not block each other.
+type WriterLock struct {
+	// the name without the schema prefix
+	name string
+	conn *sql.Conn
+}
+
+// TryWriterLock tries to take the writer lock of a coin without waiting. It returns nil (and no error) when another
+// collector holds the lock.
+func TryWriterLock(ctx context.Context, coin string) (*WriterLock, error) {
+	conn, err := GetDB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l := WriterLock{
+		name: ".forklol-writer-" + coin,
+		conn: conn,
+	}
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), ?), 0)", l.name).Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !got.Valid || got.Int64 != 1 {
+		conn.Close()
+		return nil, nil
+	}
+
+	return &l, nil
+}
+
+// Check returns ErrLockLost when the lock is no longer held by this collector
+func (l *WriterLock) Check(ctx context.Context) error {
+	var held sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(CONCAT(DATABASE(), ?)) = CONNECTION_ID()", l.name).Scan(&held); err != nil {
+		return ErrLockLost
+	}
+
+	if !held.Valid || held.Int64 != 1 {
+		return ErrLockLost
+	}
+
+	return nil
+}
+
+// Release gives up the lock and closes its connection
+func (l *WriterLock) Release() error {
+	_, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), ?))", l.name)
+	l.conn.Close()
+
+	return err
+}
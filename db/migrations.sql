@@ -0,0 +1,15 @@
+-- Schema changes on top of the original fork.lol tables, apply in order.
+
+-- previous block hash and exact node chainwork (as a decimal) of every block, run
+-- `forklol-collector recompute-work SYMBOL` for every coin afterwards
+ALTER TABLE blocks
+  ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '' AFTER hash,
+  ADD COLUMN chainwork DECIMAL(65,0) NOT NULL DEFAULT 0 AFTER prev_hash;
+
+-- getblockstats feerate_percentiles (10th, 25th, 50th, 75th and 90th percentile by weight, sat/vbyte)
+ALTER TABLE details
+  ADD COLUMN feerate_p10 BIGINT NULL,
+  ADD COLUMN feerate_p25 BIGINT NULL,
+  ADD COLUMN feerate_p50 BIGINT NULL,
+  ADD COLUMN feerate_p75 BIGINT NULL,
+  ADD COLUMN feerate_p90 BIGINT NULL;
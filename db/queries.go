@@ -1,6 +1,9 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -8,12 +11,17 @@ type Block struct {
 	Coin       string `db:"coin"`
 	Height     uint64 `db:"height"`
 	Hash       string `db:"hash"`
+	PrevHash   string `db:"prev_hash"`
+	ChainWork  string `db:"chainwork"`
 	Difficulty float64 `db:"difficulty"`
 	Work       float64 `db:"work"`
 	Time       uint64 `db:"time"`
 }
 
-// GetLastBlock returns the last block (by height) found in the database
+// ErrBrokenChain is returned by InsertBlock when the previous block hash does not match the stored block at height-1
+var ErrBrokenChain = errors.New("previous block hash does not match the stored parent block")
+
+// GetLastBlock returns the last block (by height) found in the database, sql.ErrNoRows when there is none
 func GetLastBlock(coin string) (uint64, string, error) {
 	row := struct {
 		Height    uint64 `db:"height"`
@@ -21,10 +29,6 @@ func GetLastBlock(coin string) (uint64, string, error) {
 	}{}
 
 	if err := GetDB().Get(&row, "SELECT height, hash FROM blocks WHERE coin = ? ORDER BY height DESC LIMIT 1", coin); err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			// assume db is fresh, return a block from before the fork
-			return 0, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", nil
-		}
 		return 0, "", err
 	}
 
@@ -42,27 +46,66 @@ func GetBlock(coin string, height uint64) (*Block, error) {
 	return &blk, nil
 }
 
-// InsertBlock will insert a new row into the blocks table and will return the insert_id
-func InsertBlock(tx *sqlx.Tx, coin, hash string, height, time uint64, diff, work float64) (int64, error) {
-	qry := "INSERT INTO blocks (coin, hash, height, time, difficulty, work) VALUES(?, ?, ?, ?, ?, ?)"
+// GetBlocksBetween returns the stored blocks of a coin with a height between from and to (inclusive)
+func GetBlocksBetween(coin string, from, to uint64) (*[]Block, error) {
+	blocks := make([]Block, 0, to-from+1)
+	err := GetDB().Select(&blocks, "SELECT * FROM blocks WHERE coin = ? AND height BETWEEN ? AND ? ORDER BY height", coin, from, to)
+	return &blocks, err
+}
 
-	r := tx.MustExec(qry, coin, hash, height, time, diff, work)
-	id, err := r.LastInsertId()
-	if err != nil {
-		return 0, err
+// GetHeightRange returns the lowest and highest stored height of a coin
+func GetHeightRange(coin string) (uint64, uint64, error) {
+	row := struct {
+		Min sql.NullInt64 `db:"min"`
+		Max sql.NullInt64 `db:"max"`
+	}{}
+
+	if err := GetDB().Get(&row, "SELECT MIN(height) AS min, MAX(height) AS max FROM blocks WHERE coin = ?", coin); err != nil {
+		return 0, 0, err
 	}
 
-	return id, nil
+	if !row.Min.Valid {
+		return 0, 0, sql.ErrNoRows
+	}
+
+	return uint64(row.Min.Int64), uint64(row.Max.Int64), nil
+}
+
+// GetHeightsMissingDetails returns the heights between from and to that are in the blocks table but not in details
+func GetHeightsMissingDetails(coin string, from, to uint64) ([]uint64, error) {
+	return getHeightsMissingIn("details", coin, from, to)
+}
+
+// GetHeightsMissingRates returns the heights between from and to that are in the blocks table but not in hashrates
+func GetHeightsMissingRates(coin string, from, to uint64) ([]uint64, error) {
+	return getHeightsMissingIn("hashrates", coin, from, to)
 }
 
-// InsertDetails will insert block related statistics that came from the rpc.GetBlockStats() method
-func InsertDetails(tx *sqlx.Tx, params *map[string]interface{}) (int64, error) {
-	qry := "INSERT INTO details VALUES(:coin, :height, :avgfee, :avgfeerate, :avgfeerate_old, :ins, :outs, :maxfee," +
-	  ":maxfeerate, :maxfeerate_old, :medianfee, :medianfeerate, :medianfeerate_old, :time, :mediantime, :minfee, :minfeerate," +
-	  ":minfeerate_old, :reward, :subsidy, :totalfee, :total_size, :txs, :swtotal_size, :swtotal_weight, :swtxs, :total_out," +
-	  ":total_weight, :utxo_increase, :utxo_size_inc)"
+func getHeightsMissingIn(table, coin string, from, to uint64) ([]uint64, error) {
+	heights := make([]uint64, 0)
+	qry := fmt.Sprintf("SELECT b.height FROM blocks b LEFT JOIN %s t ON t.coin = b.coin AND t.height = b.height "+
+		"WHERE b.coin = ? AND b.height BETWEEN ? AND ? AND t.height IS NULL ORDER BY b.height", table)
+
+	err := GetDB().Select(&heights, qry, coin, from, to)
+	return heights, err
+}
+
+// InsertBlock will insert a new row into the blocks table and will return the insert_id. The block is refused with
+// ErrBrokenChain when its previous block hash does not match the stored block at height-1.
+func InsertBlock(tx *sqlx.Tx, coin, hash, prevHash, chainwork string, height, time uint64, diff, work float64) (int64, error) {
+	if height > 0 {
+		parent := ""
+		err := tx.Get(&parent, "SELECT hash FROM blocks WHERE coin = ? AND height = ?", coin, height-1)
+		if err == nil && parent != prevHash {
+			return 0, ErrBrokenChain
+		} else if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	qry := "INSERT INTO blocks (coin, hash, prev_hash, chainwork, height, time, difficulty, work) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
 
-	r, err := tx.NamedExec(qry, *params)
+	r, err := tx.Exec(qry, coin, hash, prevHash, chainwork, height, time, diff, work)
 	if err != nil {
 		return 0, err
 	}
@@ -75,24 +118,46 @@ func InsertDetails(tx *sqlx.Tx, params *map[string]interface{}) (int64, error) {
 	return id, nil
 }
 
-// InsertDetailsNoSegwit will insert block related statistics that came from the rpc.GetBlockStats() method
-func InsertDetailsNoSegwit(tx *sqlx.Tx, params *map[string]interface{}) (int64, error) {
-	qry := "INSERT INTO details (coin, height, avgfee, vavgfeerate, inputs, outputs, maxfee, vmaxfeerate, medianfee, vmedianfeerate, " +
-	  "mediantime, minfee, reward,subsidy,time,totalout,totalsize,fee,txs,utxoinc,utxosizeinc) " +
-	  "VALUES(:coin, :height, :avgfee, :avgfeerate_old, :ins, :outs, :maxfee, :maxfeerate_old, :medianfee, :medianfeerate_old," +
-	  ":mediantime, :minfee, :reward, :subsidy, :time, :total_out, :total_size, :totalfee, :txs, :utxo_increase, :utxo_size_inc)"
-
-	r, err := tx.NamedExec(qry, *params)
-	if err != nil {
-		return 0, err
+// DeleteBlocksAfter removes all blocks, details, hashrates and prices of a coin above the given height. It is used
+// to roll back blocks that were orphaned by a reorg.
+func DeleteBlocksAfter(tx *sqlx.Tx, coin string, height uint64) error {
+	for _, table := range []string{"blocks", "details", "hashrates", "prices"} {
+		qry := fmt.Sprintf("DELETE FROM %s WHERE coin = ? AND height > ?", table)
+		if _, err := tx.Exec(qry, coin, height); err != nil {
+			return err
+		}
 	}
 
-	id, err := r.LastInsertId()
-	if err != nil {
-		return 0, err
+	return nil
+}
+
+// BlockWork is the exact (decimal) chainwork of a block and the float work derived from it
+type BlockWork struct {
+	Height    uint64
+	Hash      string
+	ChainWork string
+	Work      float64
+}
+
+// UpdateWork sets the chainwork and work of stored blocks. Blocks are matched by height and hash, it returns the
+// number of blocks that changed.
+func UpdateWork(tx *sqlx.Tx, coin string, works []BlockWork) (int64, error) {
+	updated := int64(0)
+	for _, w := range works {
+		r, err := tx.Exec("UPDATE blocks SET chainwork = ?, work = ? WHERE coin = ? AND height = ? AND hash = ?",
+			w.ChainWork, w.Work, coin, w.Height, w.Hash)
+		if err != nil {
+			return 0, err
+		}
+
+		n, err := r.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += n
 	}
 
-	return id, nil
+	return updated, nil
 }
 
 // GetBlocksAfter returns an array of blocks that came after a certain time
@@ -109,14 +174,22 @@ type BlockGroup struct {
 	Count      uint32 `db:"count"`
 }
 
-// GetBlocksAfterGrouped returns an array of blocks that came after a certain time grouped by the block difficulty
-func GetBlocksAfterGrouped(tx *sqlx.Tx, coin string, time uint64) (*[]BlockGroup, error) {
+// GetBlocksAfterGrouped returns an array of blocks up to a height that came after a certain time grouped by the block
+// difficulty
+func GetBlocksAfterGrouped(tx *sqlx.Tx, coin string, time, height uint64) (*[]BlockGroup, error) {
 	blocks := make([]BlockGroup, 0, 32)
-	qry := "SELECT difficulty, MAX(time) as time, COUNT(*) as count FROM blocks WHERE coin = ? AND time >= ? GROUP BY difficulty ORDER BY height"
-	err := tx.Select(&blocks, qry, coin, time)
+	qry := "SELECT difficulty, MAX(time) as time, COUNT(*) as count FROM blocks WHERE coin = ? AND time >= ? AND height <= ? GROUP BY difficulty ORDER BY height"
+	err := tx.Select(&blocks, qry, coin, time, height)
 	return &blocks, err
 }
 
+// GetHeightsBetweenTimes returns the heights of the stored blocks with a time between from and to
+func GetHeightsBetweenTimes(coin string, from, to uint64) ([]uint64, error) {
+	heights := make([]uint64, 0)
+	err := GetDB().Select(&heights, "SELECT height FROM blocks WHERE coin = ? AND time BETWEEN ? AND ? ORDER BY height", coin, from, to)
+	return heights, err
+}
+
 // InsertRates will insert hashrates for a certain coin and height
 func InsertRates(tx *sqlx.Tx, coin string, height uint64, rates *map[string]float64) error {
 	_, err := tx.Exec("INSERT INTO hashrates VALUES(?,?,?,?,?,?,?,?,?)",
@@ -133,3 +206,12 @@ func InsertRates(tx *sqlx.Tx, coin string, height uint64, rates *map[string]floa
 
 	return err
 }
+
+// ReplaceRates replaces the hashrates of a block
+func ReplaceRates(tx *sqlx.Tx, coin string, height uint64, rates *map[string]float64) error {
+	if _, err := tx.Exec("DELETE FROM hashrates WHERE coin = ? AND height = ?", coin, height); err != nil {
+		return err
+	}
+
+	return InsertRates(tx, coin, height, rates)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"forklol-collector/bitcoin"
+	"forklol-collector/config"
+	"os"
+)
+
+// runForkPoint implements the forkpoint subcommand: forkpoint [-save] PARENT CHILD. It returns the exit code.
+func runForkPoint(args []string) int {
+	fs := flag.NewFlagSet("forkpoint", flag.ExitOnError)
+	save := fs.Bool("save", false, "write the fork point into the fork registry of the config file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] forkpoint [-save] PARENT CHILD\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	coins := make([]bitcoin.Coin, 2)
+	for n, symbol := range fs.Args() {
+		opts, ok := findCoin(symbol)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Coin %s is not configured.\n", symbol)
+			return 2
+		}
+
+		c, err := bitcoin.NewCoin(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not configure coin %s\n", err.Error())
+			return 2
+		}
+		coins[n] = c
+	}
+
+	parent, child := coins[0], coins[1]
+
+	point, err := bitcoin.FindForkPoint(context.Background(), parent, child)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not find the fork point of %s and %s: %s\n", parent.Symbol, child.Symbol, err.Error())
+		return 1
+	}
+
+	fmt.Printf("%s split off %s after block %d (%s)\n", child.Symbol, parent.Symbol, point.Height, point.Hash)
+	fmt.Printf("  time:      %d\n", point.Time)
+	fmt.Printf("  chainwork: %s\n", point.ChainWork.String())
+
+	if *save {
+		path := config.Options().CONFIG_FILE
+		if err := config.SaveFork(path, point.Fork(parent.Symbol, child.Symbol)); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not save the fork to %s: %s\n", path, err.Error())
+			return 1
+		}
+
+		fmt.Printf("Saved to the fork registry in %s\n", path)
+	}
+
+	return 0
+}
@@ -1,82 +1,85 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"flag"
 	"fmt"
 	"forklol-collector/config"
 	"forklol-collector/db"
-	"forklol-collector/bitcoin"
-	"time"
 	"log"
+	"syscall"
+	"time"
 )
 
-var coins []bitcoin.Coin
+// time the syncers get to finish their current block after SIGINT/SIGTERM
+const shutdownTimeout = time.Minute
 
 func main() {
 	Init()
 	db.InitDB(config.Options().DB_CONNECTION_STRING)
 
-	// FIXME: get from config file
-	coins = []bitcoin.Coin{
-		{
-			Symbol:   "BTC",
-			RPCUrl:   "http://192.168.1.2:8332/",
-			RPCUser:  "forklol",
-			RPCPass:  "forklol",
-			RPCStats: true,
-			SegWit:   true,
-		},
-		{
-			Symbol:   "BCH",
-			RPCUrl:   "http://192.168.1.2:8331/",
-			RPCUser:  "forklol",
-			RPCPass:  "forklol",
-			RPCStats: true,
-			SegWit:   false,
-		},
+	switch flag.Arg(0) {
+	case "verify":
+		os.Exit(runVerify(flag.Args()[1:]))
+	case "recompute-work":
+		os.Exit(runRecomputeWork(flag.Args()[1:]))
+	case "forkpoint":
+		os.Exit(runForkPoint(flag.Args()[1:]))
 	}
 
-	syncers := make([]bitcoin.ChainSync, 0)
+	coins, err := newCoins(config.Options().Coins())
+	if err != nil {
+		log.Fatalf("Could not load config: %s\n", err.Error())
+	}
 
-	done := make(chan bool)
+	ctx, cancel := context.WithCancel(context.Background())
 
-	// initial sync
-	for _, coin := range coins {
-		sync := bitcoin.NewChainSync(coin)
-		go sync.Sync(done)
+	applyCoins(ctx, coins)
+	go watchReload(ctx)
 
-		syncers = append(syncers, sync)
-	}
+	// every coin syncs in its own loop (see bitcoin.ChainSync.Run) until the collector is told to stop
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
-	for n := 0; n < len(syncers); n++ {
-		<-done
-	}
-	close(done)
+	log.Printf("%s received, shutting down.\n", <-sig)
+	cancel()
 
-	RunSyncers(syncers)
+	os.Exit(shutdown(sig))
 }
 
-func RunSyncers(syncers []bitcoin.ChainSync) {
-	t := time.NewTicker(time.Second * 5)
-
-	for {
-		done := make(chan bool)
-		select {
-		case <-t.C:
-			t.Stop()
-			for _, sync := range syncers {
-				log.Printf("Checking for new %s blocks.\n", sync.Coin.Symbol)
-				go sync.Sync(done)
-			}
-		}
-
-		for n := 0; n < len(syncers); n++ {
-			<-done
-		}
-		close(done)
-		t = time.NewTicker(time.Second * 5)
+// shutdown waits for all syncers to commit or roll back the block they are on and closes the database. It returns
+// exit code 0 when everything stopped cleanly, and 1 when syncers were still running after shutdownTimeout or
+// after another signal.
+func shutdown(sig <-chan os.Signal) int {
+	stopped := make(chan struct{})
+	go func() {
+		syncers.wg.Wait()
+		close(stopped)
+	}()
+
+	t := time.NewTimer(shutdownTimeout)
+	defer t.Stop()
+
+	select {
+	case <-stopped:
+	case <-t.C:
+		log.Printf("Syncers still running after %s, exiting anyway.\n", shutdownTimeout)
+		return 1
+	case s := <-sig:
+		log.Printf("%s received again, exiting without waiting for syncers.\n", s)
+		return 1
+	}
+
+	if err := db.CloseDB(); err != nil {
+		log.Printf("Could not close database: %s\n", err.Error())
+		return 1
 	}
+
+	log.Printf("Shut down cleanly.\n")
+
+	return 0
 }
 
 // init parses arguments and sets config.Options
@@ -91,10 +94,22 @@ func Init() {
 	env_dbport, _ := os.LookupEnv("FORKLOL_DB_PORT")
 	env_dbschm, _ := os.LookupEnv("FORKLOL_DB_SCHEME")
 
+	env_config, ok := os.LookupEnv("FORKLOL_CONFIG")
+	if !ok {
+		env_config = "config.json"
+	}
+
+	env_rpcbtc, _ := os.LookupEnv("FORKLOL_RPC_BTC")
+	env_rpcbch, _ := os.LookupEnv("FORKLOL_RPC_BCH")
+	env_rpctbtc, _ := os.LookupEnv("FORKLOL_RPC_TBTC")
+	env_rpcelm, _ := os.LookupEnv("FORKLOL_RPC_ELM")
+	env_rpclqd, _ := os.LookupEnv("FORKLOL_RPC_LQD")
+
 	// set argument flags
 	pub := flag.String("pubkey", env_pubkey, "bitcoinaverage.com api public key, defaults to env var FORKLOL_BTCAVG_PUBKEY")
 	sec := flag.String("secret", env_secret, "bitcoinaverage.com api secret, defaults to env var FORKLOL_BTCAVG_SECRET")
 	dbg := flag.Bool("debug", false, "enable debugging")
+	cfg := flag.String("config", env_config, "path to the json config file with coin definitions, defaults to env var FORKLOL_CONFIG or config.json")
 
 	dbuser := flag.String("dbuser", env_dbuser, "mysql user")
 	dbpass := flag.String("dbpass", env_dbpass, "mysql password")
@@ -102,13 +117,55 @@ func Init() {
 	dbport := flag.String("dbport", env_dbport, "mysql port")
 	dbscheme := flag.String("dbscheme", env_dbschm, "mysql database name/scheme")
 
+	rpcbtc := flag.String("rpc-btc", env_rpcbtc, "rpc url of the BTC node, overrides the config file")
+	rpcbch := flag.String("rpc-bch", env_rpcbch, "rpc url of the BCH node, overrides the config file")
+	rpctbtc := flag.String("rpc-tbtc", env_rpctbtc, "rpc url of the TBTC node, overrides the config file")
+	rpcelm := flag.String("rpc-elm", env_rpcelm, "rpc url of the ELM node, overrides the config file")
+	rpclqd := flag.String("rpc-lqd", env_rpclqd, "rpc url of the LQD node, overrides the config file")
+
 	flag.Parse()
 
+	// values from the config file are used when neither env vars nor flags are set
+	file, err := config.ReadFile(*cfg)
+	if err != nil {
+		log.Fatalf("Could not load config: %s\n", err.Error())
+	}
+
+	fallback(dbuser, file.DBUser)
+	fallback(dbpass, file.DBPass)
+	fallback(dbhost, file.DBHost)
+	fallback(dbport, file.DBPort)
+	fallback(dbscheme, file.DBScheme)
+	fallback(pub, file.BTCAvgPubkey)
+	fallback(sec, file.BTCAvgSecret)
+
 	// set config.Optios
 	opts := config.Options()
 
 	opts.DEBUG = *dbg
+	opts.CONFIG_FILE = *cfg
 	opts.DB_CONNECTION_STRING = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", *dbuser, *dbpass, *dbhost, *dbport, *dbscheme)
 	opts.BTCAVG_PUBKEY = *pub
 	opts.BTCAVG_SECRET = *sec
+
+	opts.RPC_BTC = *rpcbtc
+	opts.RPC_BCH = *rpcbch
+	opts.RPC_TBTC = *rpctbtc
+	opts.RPC_ELM = *rpcelm
+	opts.RPC_LQD = *rpclqd
+
+	if err := opts.Configure(file.Coins, file.Forks); err != nil {
+		log.Fatalf("Could not load config: %s\n", err.Error())
+	}
+
+	if len(opts.Coins()) == 0 {
+		log.Fatalf("No coins configured in %s\n", *cfg)
+	}
+}
+
+// fallback sets val to def when val is empty
+func fallback(val *string, def string) {
+	if *val == "" {
+		*val = def
+	}
 }
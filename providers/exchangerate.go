@@ -29,9 +29,10 @@ func NewExchangeRateFetcher(symbol string) *ExchangeRateFetcher {
 	return &pf
 }
 
-// GetExchangeRate returns the exchange rate of a coin at a certain point in time
+// GetExchangeRate returns the exchange rate of a coin at a certain point in time, 0 for the blocks a forked coin shares
+// with its parent
 func (p ExchangeRateFetcher) GetExchangeRate(height, timestamp uint64) (float64, error) {
-	if height <= config.CHAINSPLIT_HEIGHT {
+	if fork, ok := config.Options().ForkOf(p.symbol); ok && height <= fork.Height {
 		return 0.0, nil
 	}
 
@@ -55,7 +56,7 @@ func (p ExchangeRateFetcher) GetExchangeRate(height, timestamp uint64) (float64,
 	return er.ExchangeRate, nil
 }
 
-func (p ExchangeRateFetcher) init(sym string) {
+func (p *ExchangeRateFetcher) init(sym string) {
 	p.symbol = sym
 	p.pubkey = config.Options().BTCAVG_PUBKEY
 	p.secret = config.Options().BTCAVG_SECRET
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"forklol-collector/bitcoin"
+	"os"
+)
+
+// runRecomputeWork implements the recompute-work subcommand: recompute-work SYMBOL [FROM [TO]]. It returns the exit
+// code.
+func runRecomputeWork(args []string) int {
+	fs := flag.NewFlagSet("recompute-work", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] recompute-work SYMBOL [FROM [TO]]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 3 {
+		fs.Usage()
+		return 2
+	}
+
+	coin, ok := findCoin(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Coin %s is not configured.\n", fs.Arg(0))
+		return 2
+	}
+
+	from, to, code := heightRange(coin.Symbol, fs.Args()[1:])
+	if code != 0 {
+		return code
+	}
+
+	c, err := bitcoin.NewCoin(coin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not configure coin %s\n", err.Error())
+		return 2
+	}
+
+	changed, err := bitcoin.NewChainSync(c).RecomputeWork(context.Background(), from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not recompute %s work: %s\n", coin.Symbol, err.Error())
+		return 1
+	}
+
+	fmt.Printf("Recomputed the work of %s blocks %d to %d, %d blocks changed.\n", coin.Symbol, from, to, changed)
+
+	return 0
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"forklol-collector/bitcoin"
+	"forklol-collector/config"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// syncers holds a ChainSync for every configured coin, by symbol. wg tracks the running sync loops.
+var syncers = struct {
+	sync.Mutex
+	m  map[string]*bitcoin.ChainSync
+	wg sync.WaitGroup
+}{
+	m: map[string]*bitcoin.ChainSync{},
+}
+
+// watchReload reloads the coin configuration every time a SIGHUP is received, until ctx is cancelled
+func watchReload(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-sig:
+			log.Printf("SIGHUP received, reloading %s\n", config.Options().CONFIG_FILE)
+			reloadCoins(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reloadCoins re-reads the coins from the config file. The old configuration is kept when the file is invalid or one
+// of its coins can't be set up.
+func reloadCoins(ctx context.Context) {
+	opts := config.Options()
+
+	file, err := config.ReadFile(opts.CONFIG_FILE)
+	if err != nil {
+		log.Printf("Could not reload config, keeping current coins: %s\n", err.Error())
+		return
+	}
+
+	options, forks, err := opts.Validate(file.Coins, file.Forks)
+	if err != nil {
+		log.Printf("Could not reload config, keeping current coins: %s\n", err.Error())
+		return
+	}
+
+	coins, err := newCoins(options)
+	if err != nil {
+		log.Printf("Could not reload config, keeping current coins: %s\n", err.Error())
+		return
+	}
+
+	opts.Store(options, forks)
+	applyCoins(ctx, coins)
+}
+
+// newCoins sets up the rpc clients of the given coins, it fails when any of them can't be set up
+func newCoins(options []config.CoinOptions) ([]bitcoin.Coin, error) {
+	coins := make([]bitcoin.Coin, 0, len(options))
+
+	for _, opts := range options {
+		coin, err := bitcoin.NewCoin(opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not configure coin %s: %s", opts.Symbol, err.Error())
+		}
+
+		coins = append(coins, coin)
+	}
+
+	return coins, nil
+}
+
+// applyCoins creates and runs syncers for new coins, updates the ones that are still configured and stops removed
+// ones. Stopped syncers finish the block they are working on before returning. New syncers run until ctx is
+// cancelled.
+func applyCoins(ctx context.Context, coins []bitcoin.Coin) {
+	syncers.Lock()
+	defer syncers.Unlock()
+
+	configured := map[string]bool{}
+
+	for _, coin := range coins {
+		configured[coin.Symbol] = true
+
+		if s, ok := syncers.m[coin.Symbol]; ok {
+			if err := s.UpdateCoin(coin); err != nil {
+				log.Printf("Could not update %s configuration: %s\n", coin.Symbol, err.Error())
+				continue
+			}
+			log.Printf("Updated %s configuration.\n", coin.Symbol)
+			continue
+		}
+
+		s := bitcoin.NewChainSync(coin)
+
+		syncers.wg.Add(1)
+		go func() {
+			defer syncers.wg.Done()
+			s.Run(ctx)
+		}()
+
+		syncers.m[coin.Symbol] = s
+		log.Printf("Added %s syncer.\n", coin.Symbol)
+	}
+
+	for sym, s := range syncers.m {
+		if !configured[sym] {
+			s.Stop()
+			delete(syncers.m, sym)
+			log.Printf("Removed %s syncer.\n", sym)
+		}
+	}
+}
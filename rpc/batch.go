@@ -0,0 +1,230 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// BatchCall is a single method call of a batch request
+type BatchCall struct {
+	Method string
+	Params interface{}
+}
+
+// BatchResult is the result of a single call of a batch request. Error is set (to an *Error) when that call failed.
+type BatchResult struct {
+	Result json.RawMessage
+	Error  error
+}
+
+// Batch performs all calls in one json-rpc batch request. The results are returned in the same order as the calls.
+func (c *Client) Batch(ctx context.Context, calls []BatchCall) ([]BatchResult, error) {
+	results, _, err := c.batch(ctx, calls)
+	return results, err
+}
+
+// batch is Batch, but also returns the url of the endpoint that served the batch
+func (c *Client) batch(ctx context.Context, calls []BatchCall) ([]BatchResult, string, error) {
+	if len(calls) == 0 {
+		return []BatchResult{}, "", nil
+	}
+
+	batch := make([]call, len(calls))
+	for n, bc := range calls {
+		batch[n] = call{
+			JsonRPC: "1.0",
+			Id:      strconv.Itoa(n),
+			Method:  bc.Method,
+			Params:  bc.Params,
+		}
+	}
+
+	j, err := json.Marshal(batch)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var responses []struct {
+		Id     string          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+
+	var served string
+
+	err = c.retry(ctx, func() error {
+		served, err = c.do(ctx, "batch", j, func(body []byte, status int) error {
+			if err := json.Unmarshal(body, &responses); err != nil {
+				return &TransportError{Method: "batch", Status: status, Err: err}
+			}
+
+			// a warming up node fails every call, retry the whole batch
+			for _, resp := range responses {
+				if resp.Error != nil && resp.Error.Code == ErrCodeWarmup {
+					return resp.Error
+				}
+			}
+
+			return nil
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := make([]BatchResult, len(calls))
+	found := make([]bool, len(calls))
+
+	for _, resp := range responses {
+		n, err := strconv.Atoi(resp.Id)
+		if err != nil || n < 0 || n >= len(calls) {
+			return nil, "", fmt.Errorf("unexpected id %q in batch response", resp.Id)
+		}
+
+		found[n] = true
+		if resp.Error != nil {
+			resp.Error.Method = calls[n].Method
+			results[n].Error = resp.Error
+			continue
+		}
+
+		results[n].Result = resp.Result
+	}
+
+	for n := range results {
+		if !found[n] {
+			results[n].Error = fmt.Errorf("%s: no response in batch", calls[n].Method)
+		}
+	}
+
+	return results, served, nil
+}
+
+// batchDecode performs a batch request and decodes every result into dest(n), failing on the first call error. It
+// returns the url of the endpoint that served the batch.
+func (c *Client) batchDecode(ctx context.Context, calls []BatchCall, dest func(n int) interface{}) (string, error) {
+	results, served, err := c.batch(ctx, calls)
+	if err != nil {
+		return "", err
+	}
+
+	for n, res := range results {
+		if res.Error != nil {
+			return "", res.Error
+		}
+
+		if err := json.Unmarshal(res.Result, dest(n)); err != nil {
+			return "", err
+		}
+	}
+
+	return served, nil
+}
+
+// GetBlockHashes returns the blockhashes of all blocks between from and to (inclusive) in one batch request
+func (c *Client) GetBlockHashes(ctx context.Context, from, to uint64) ([]string, error) {
+	if to < from {
+		return []string{}, nil
+	}
+
+	calls := make([]BatchCall, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		calls = append(calls, BatchCall{Method: "getblockhash", Params: []uint64{h}})
+	}
+
+	hashes := make([]string, len(calls))
+	_, err := c.batchDecode(ctx, calls, func(n int) interface{} {
+		return &hashes[n]
+	})
+
+	return hashes, err
+}
+
+// GetBlocks returns the blocks with the given blockhashes in one batch request. With verbosity 1 only the txids of
+// the transactions are set, with 2 the decoded transactions and with 3 also the prevouts of their inputs.
+func (c *Client) GetBlocks(ctx context.Context, hashes []string, verbosity int) ([]*VerboseBlock, error) {
+	calls := make([]BatchCall, len(hashes))
+	for n, hash := range hashes {
+		calls[n] = BatchCall{Method: "getblock", Params: []interface{}{hash, verbosity}}
+	}
+
+	blocks := make([]*VerboseBlock, len(hashes))
+	served, err := c.batchDecode(ctx, calls, func(n int) interface{} {
+		blocks[n] = &VerboseBlock{}
+		return blocks[n]
+	})
+
+	for _, block := range blocks {
+		if block != nil {
+			block.ServedBy = served
+		}
+	}
+
+	return blocks, err
+}
+
+// GetBlockHeaders returns the headers of the blocks with the given blockhashes in one batch request
+func (c *Client) GetBlockHeaders(ctx context.Context, hashes []string) ([]*Block, error) {
+	calls := make([]BatchCall, len(hashes))
+	for n, hash := range hashes {
+		calls[n] = BatchCall{Method: "getblockheader", Params: []string{hash}}
+	}
+
+	blocks := make([]*Block, len(hashes))
+	served, err := c.batchDecode(ctx, calls, func(n int) interface{} {
+		blocks[n] = &Block{}
+		return blocks[n]
+	})
+
+	for _, block := range blocks {
+		if block != nil {
+			block.ServedBy = served
+		}
+	}
+
+	return blocks, err
+}
+
+// GetBlockStatsRange returns the statistics of all blocks between from and to (inclusive) in one batch request
+func (c *Client) GetBlockStatsRange(ctx context.Context, from, to uint64) ([]*BlockStats, error) {
+	if to < from {
+		return []*BlockStats{}, nil
+	}
+
+	calls := make([]BatchCall, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		calls = append(calls, BatchCall{Method: "getblockstats", Params: []uint64{h, h}})
+	}
+
+	stats := make([]*BlockStats, len(calls))
+	_, err := c.batchDecode(ctx, calls, func(n int) interface{} {
+		stats[n] = &BlockStats{}
+		return stats[n]
+	})
+
+	return stats, err
+}
+
+// GetStockBlockStatsRange returns the statistics of all blocks between from and to (inclusive) in one batch request
+// to a stock node, see GetStockBlockStats
+func (c *Client) GetStockBlockStatsRange(ctx context.Context, from, to uint64) ([]StockBlockStats, error) {
+	if to < from {
+		return []StockBlockStats{}, nil
+	}
+
+	calls := make([]BatchCall, 0, to-from+1)
+	for h := from; h <= to; h++ {
+		calls = append(calls, BatchCall{Method: "getblockstats", Params: []uint64{h}})
+	}
+
+	stats := make([]StockBlockStats, len(calls))
+	_, err := c.batchDecode(ctx, calls, func(n int) interface{} {
+		return &stats[n]
+	})
+
+	return stats, err
+}
@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// batchNode answers batch requests in reverse order, like nodes are allowed to. getblockhash returns "hash<height>",
+// getblock echoes its verbosity, "fail" returns an invalid parameter error and "drop" gets no response.
+func batchNode(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		var calls []struct {
+			Id     string            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &calls); err != nil {
+			t.Errorf("not a batch request: %s", body)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		responses := make([]map[string]interface{}, 0, len(calls))
+		for n := len(calls) - 1; n >= 0; n-- {
+			c := calls[n]
+			resp := map[string]interface{}{"id": c.Id, "result": nil, "error": nil}
+
+			switch c.Method {
+			case "getblockhash":
+				resp["result"] = fmt.Sprintf("hash%s", c.Params[0])
+			case "getblock":
+				var hash string
+				json.Unmarshal(c.Params[0], &hash)
+				resp["result"] = map[string]interface{}{"hash": hash, "height": len(responses), "tx": []string{"txid" + string(c.Params[1])}}
+			case "fail":
+				resp["error"] = map[string]interface{}{"code": ErrCodeInvalidParameter, "message": "Invalid parameter"}
+			case "drop":
+				continue
+			}
+
+			responses = append(responses, resp)
+		}
+
+		json.NewEncoder(w).Encode(responses)
+	}))
+}
+
+func batchClient(t *testing.T, url string) *Client {
+	c, err := NewClient([]Endpoint{{URL: url}}, Options{Timeout: 5 * time.Second, Retries: 1, RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestBatch(t *testing.T) {
+	node := batchNode(t)
+	defer node.Close()
+
+	c := batchClient(t, node.URL)
+
+	results, err := c.Batch(context.Background(), []BatchCall{
+		{Method: "getblockhash", Params: []uint64{1}},
+		{Method: "fail", Params: []uint64{2}},
+		{Method: "drop", Params: []uint64{3}},
+		{Method: "getblockhash", Params: []uint64{4}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		result  string
+		invalid bool
+		missing bool
+	}{
+		{`"hash1"`, false, false},
+		{"", true, false},
+		{"", false, true},
+		{`"hash4"`, false, false},
+	}
+
+	for n, test := range tests {
+		res := results[n]
+
+		if string(res.Result) != test.result {
+			t.Errorf("result %d is %s, expected %s", n, res.Result, test.result)
+		}
+
+		if IsInvalidParameter(res.Error) != test.invalid {
+			t.Errorf("result %d has error %v", n, res.Error)
+		}
+
+		if test.missing && res.Error == nil {
+			t.Errorf("result %d has no response but no error", n)
+		}
+	}
+
+	if e, ok := results[1].Error.(*Error); !ok || e.Method != "fail" {
+		t.Errorf("the error of result 1 is not attributed to its method: %v", results[1].Error)
+	}
+}
+
+func TestGetBlockHashes(t *testing.T) {
+	node := batchNode(t)
+	defer node.Close()
+
+	c := batchClient(t, node.URL)
+
+	tests := []struct {
+		from, to uint64
+		expected []string
+	}{
+		{5, 8, []string{"hash5", "hash6", "hash7", "hash8"}},
+		{3, 3, []string{"hash3"}},
+		{4, 3, []string{}},
+	}
+
+	for _, test := range tests {
+		hashes, err := c.GetBlockHashes(context.Background(), test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(hashes, test.expected) {
+			t.Errorf("hashes %d-%d are %v, expected %v", test.from, test.to, hashes, test.expected)
+		}
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	node := batchNode(t)
+	defer node.Close()
+
+	c := batchClient(t, node.URL)
+
+	blocks, err := c.GetBlocks(context.Background(), []string{"a", "b", "c"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n, hash := range []string{"a", "b", "c"} {
+		if blocks[n].Hash != hash {
+			t.Errorf("block %d is %s, expected %s", n, blocks[n].Hash, hash)
+		}
+
+		// at verbosity 1 the transactions are only txids
+		if len(blocks[n].Tx) != 1 || blocks[n].Tx[0].Txid != "txid1" {
+			t.Errorf("block %d has transactions %+v", n, blocks[n].Tx)
+		}
+
+		if blocks[n].ServedBy != node.URL {
+			t.Errorf("block %d was served by %s", n, blocks[n].ServedBy)
+		}
+	}
+}
+
+func TestBatchDecodeFails(t *testing.T) {
+	node := batchNode(t)
+	defer node.Close()
+
+	c := batchClient(t, node.URL)
+
+	calls := []BatchCall{{Method: "getblockhash", Params: []uint64{1}}, {Method: "fail", Params: []uint64{2}}}
+	hashes := make([]string, len(calls))
+	_, err := c.batchDecode(context.Background(), calls, func(n int) interface{} { return &hashes[n] })
+
+	if !IsInvalidParameter(err) {
+		t.Errorf("expected the error of the failing call, got %v", err)
+	}
+}
@@ -1,11 +1,11 @@
 package rpc
 
 import (
+	"context"
 	"net/http"
-	"bytes"
-	"io/ioutil"
 	"encoding/json"
-	"errors"
+	"sync"
+	"time"
 )
 
 type call struct {
@@ -15,23 +15,85 @@ type call struct {
 	Params  interface{} `json:"params"`
 }
 
+// Options configure the timeout and retries of every call a Client makes
+type Options struct {
+	// Timeout of a single attempt of a call
+	Timeout time.Duration
+	// Retries is the number of times a call is retried after a transient error
+	Retries int
+	// RetryDelay is the wait before the first retry, it is doubled after every attempt
+	RetryDelay time.Duration
+	// MaxLag is the number of blocks an endpoint may be behind the others before it is avoided
+	MaxLag uint64
+}
+
+// DefaultOptions are used for every option that is not set
+var DefaultOptions = Options{
+	Timeout:    30 * time.Second,
+	Retries:    5,
+	RetryDelay: time.Second,
+	MaxLag:     2,
+}
+
+// maximum wait between two attempts of a call
+const maxRetryDelay = time.Minute
+
+// endpoints without tls options share one transport so connections to the nodes are reused
+var httpClient = &http.Client{
+	Transport: plainTransport(),
+}
+
+// Client calls rpc methods on one or more bitcoind endpoints of the same chain, see Endpoint
 type Client struct {
-	url  string
-	user string
-	pass string
+	endpoints []*endpoint
+	opts      Options
+
+	mu sync.RWMutex
+}
+
+// NewClient returns a new Client object to call rpc methods with. It fails when the tls files of an endpoint can not
+// be loaded.
+func NewClient(endpoints []Endpoint, opts Options) (*Client, error) {
+	c := &Client{}
+	if err := c.SetEndpoints(endpoints); err != nil {
+		return nil, err
+	}
+	c.SetOptions(opts)
+
+	return c, nil
 }
 
-// NewClient returns a new Client object to call rpc methods with
-func NewClient(url, user, pass string) Client {
-	return Client{
-		url:  url,
-		user: user,
-		pass: pass,
+// SetOptions replaces the timeout and retry options used for subsequent calls
+func (c *Client) SetOptions(opts Options) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
 	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultOptions.RetryDelay
+	}
+	if opts.MaxLag == 0 {
+		opts.MaxLag = DefaultOptions.MaxLag
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.opts = opts
 }
 
-// Call performs a method call to bitcoind rpc and returns the result as a *[]byte
-func (c Client) Call(method string, params interface{}) (*[]byte, error) {
+// Call performs a method call to bitcoind rpc and returns the response as a *[]byte. Errors reported by bitcoind are
+// returned as *Error, failing requests and responses that are not json-rpc as *TransportError. Transient errors
+// fail over to the next endpoint and are retried with exponential backoff.
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (*[]byte, error) {
+	body, _, err := c.call(ctx, method, params)
+	return body, err
+}
+
+// call is Call, but also returns the url of the endpoint that served the call
+func (c *Client) call(ctx context.Context, method string, params interface{}) (*[]byte, string, error) {
 	call := call{
 		JsonRPC: "1.0",
 		Id:      "forklol",
@@ -41,32 +103,94 @@ func (c Client) Call(method string, params interface{}) (*[]byte, error) {
 
 	j, err := json.Marshal(call)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	req, err := http.NewRequest("POST", c.url, bytes.NewBuffer(j))
-	req.SetBasicAuth(c.user, c.pass)
-	req.Header.Set("Content-Type", "application/json")
+	var body []byte
+	var served string
+
+	err = c.retry(ctx, func() error {
+		served, err = c.do(ctx, method, j, func(b []byte, status int) error {
+			body = b
+			return checkResponse(method, b, status)
+		})
+		return err
+	})
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
 	if err != nil {
-		panic(err)
+		return nil, "", err
 	}
-	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	return &body, served, nil
+}
+
+// checkResponse returns the error of a json-rpc response
+func checkResponse(method string, body []byte, status int) error {
+	resp := struct {
+		Error *Error `json:"error"`
+	}{}
+
+	// bitcoind answers errors with a json body and http status 404/500, anything else that is not json (bad
+	// credentials, a proxy error page, ...) is a transport error
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return &TransportError{Method: method, Status: status, Err: err}
+	}
 
-	if resp.StatusCode == 500 {
-		return nil, errors.New("bad request")
+	if resp.Error != nil {
+		resp.Error.Method = method
+		return resp.Error
 	}
 
-	return &body, nil
+	return nil
+}
+
+// retry calls fn until it succeeds, fails with an error that is not transient or runs out of retries
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	c.mu.RLock()
+	opts := c.opts
+	c.mu.RUnlock()
+
+	delay := opts.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		if err == nil || attempt >= opts.Retries || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// isTransient returns true for errors that might go away by themselves: a node that is warming up, refusing
+// connections, timing out or behind a proxy that can not reach it
+func isTransient(err error) bool {
+	if IsWarmingUp(err) {
+		return true
+	}
+
+	if e, ok := err.(*TransportError); ok {
+		switch e.Status {
+		case 0, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+
+	return false
 }
 
 // GetLastBlock returns the height and blockhash of the last (or "best") block
-func (c Client) GetLastBlock() (uint64, string, error) {
-	j, err := c.Call("getblockchaininfo", []string{})
+func (c *Client) GetLastBlock(ctx context.Context) (uint64, string, error) {
+	j, err := c.Call(ctx, "getblockchaininfo", []string{})
 	if err != nil {
 		return 0, "", err
 	}
@@ -86,10 +210,10 @@ func (c Client) GetLastBlock() (uint64, string, error) {
 }
 
 // GetBlockHash returns the blockhash of a block at the specified height
-func (c Client) GetBlockHash(height uint64) (string, error) {
+func (c *Client) GetBlockHash(ctx context.Context, height uint64) (string, error) {
 	params := []uint64{height}
 
-	j, err := c.Call("getblockhash", params)
+	j, err := c.Call(ctx, "getblockhash", params)
 	if err != nil {
 		return "", err
 	}
@@ -109,16 +233,21 @@ func (c Client) GetBlockHash(height uint64) (string, error) {
 type Block struct {
 	Height     uint64 `json:"height"`
 	Hash       string `json:"hash"`
+	PrevHash   string `json:"previousblockhash"`
+	ChainWork  string `json:"chainwork"`
 	Size       uint64 `json:"size"`
 	Weight     uint64 `json:"weight"`
 	Time       uint64 `json:"time"`
 	MedianTime uint64 `json:"mediantime"`
 	Difficulty float64 `json:"difficulty"`
+
+	// url of the endpoint that returned the block
+	ServedBy string `json:"-"`
 }
 
 // GetBlock returns a some basic information about a block with the given blockhash
-func (c Client) GetBlock(blockhash string) (*Block, error) {
-	j, err := c.Call("getblock", []string{blockhash})
+func (c *Client) GetBlock(ctx context.Context, blockhash string) (*Block, error) {
+	j, served, err := c.call(ctx, "getblock", []string{blockhash})
 	if err != nil {
 		return nil, err
 	}
@@ -130,18 +259,40 @@ func (c Client) GetBlock(blockhash string) (*Block, error) {
 	if err = json.Unmarshal(*j, &t); err != nil {
 		return nil, err
 	}
+	t.Result.ServedBy = served
 
 	return &t.Result, nil
 }
 
-// BlockStats is used by rpc.GetBlockStats()
+// GetBlockHeader returns the same information as GetBlock (without size and weight), but without the txid list
+func (c *Client) GetBlockHeader(ctx context.Context, blockhash string) (*Block, error) {
+	j, served, err := c.call(ctx, "getblockheader", []string{blockhash})
+	if err != nil {
+		return nil, err
+	}
+
+	t := struct {
+		Result Block `json:"result"`
+	}{}
+
+	if err = json.Unmarshal(*j, &t); err != nil {
+		return nil, err
+	}
+	t.Result.ServedBy = served
+
+	return &t.Result, nil
+}
+
+// BlockStats is used by rpc.GetBlockStats(). Every value is an array with one entry per block of the requested range.
 type BlockStats map[string][]interface{}
 
-// GetBlockStats will return statistics about the block with the given height. RPC method "getblockstats" will be used.
-func (c Client) GetBlockStats(height uint64) (*BlockStats, error) {
+// GetBlockStats will return statistics about the block with the given height. RPC method "getblockstats" will be used
+// with a range of heights, which only the patched fork.lol nodes accept. Stock nodes fail with ErrCodeType, use
+// GetStockBlockStats for them.
+func (c *Client) GetBlockStats(ctx context.Context, height uint64) (*BlockStats, error) {
 	params := []uint64{height, height}
 
-	j, err := c.Call("getblockstats", params)
+	j, err := c.Call(ctx, "getblockstats", params)
 	if err != nil {
 		return nil, err
 	}
@@ -156,3 +307,25 @@ func (c Client) GetBlockStats(height uint64) (*BlockStats, error) {
 
 	return &t.Result, nil
 }
+
+// StockBlockStats is used by rpc.GetStockBlockStats(), the values are those of a single block
+type StockBlockStats map[string]interface{}
+
+// GetStockBlockStats returns the statistics of the block at height from getblockstats the way stock nodes (Core,
+// Knots, ABC, BCHN, Elements) take it, with a single height
+func (c *Client) GetStockBlockStats(ctx context.Context, height uint64) (StockBlockStats, error) {
+	j, err := c.Call(ctx, "getblockstats", []uint64{height})
+	if err != nil {
+		return nil, err
+	}
+
+	t := struct {
+		Result StockBlockStats `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(*j, &t); err != nil {
+		return nil, err
+	}
+
+	return t.Result, nil
+}
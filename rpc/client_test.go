@@ -0,0 +1,196 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetStockBlockStats(t *testing.T) {
+	// a stock node takes a single height and rejects a height as the second parameter
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c struct {
+			Params []interface{} `json:"params"`
+		}
+		decodeBody(r, &c)
+
+		if len(c.Params) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result": null, "error": {"code": -3, "message": "Expected type array, got number"}, "id": "forklol"}`))
+			return
+		}
+
+		w.Write([]byte(`{"result": {"avgfee": 25000, "feerate_percentiles": [2, 10, 40, 80, 150], "txs": 2000}, "error": null, "id": "forklol"}`))
+	}))
+	defer node.Close()
+
+	c := batchClient(t, node.URL)
+
+	stats, err := c.GetStockBlockStats(context.Background(), 500000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats["avgfee"] != 25000.0 || stats["txs"] != 2000.0 || len(stats["feerate_percentiles"].([]interface{})) != 5 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	if _, err := c.GetBlockStats(context.Background(), 500000); !hasCode(err, ErrCodeType) {
+		t.Errorf("expected the range form to fail with %d, got %v", ErrCodeType, err)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   int
+		http   int
+	}{
+		{"result", `{"result": 1, "error": null, "id": "forklol"}`, http.StatusOK, 0, 0},
+		{"rpc error", `{"result": null, "error": {"code": -5, "message": "Block not found"}, "id": "forklol"}`, http.StatusNotFound, ErrCodeBlockNotFound, 0},
+		{"bad credentials", ``, http.StatusUnauthorized, 0, http.StatusUnauthorized},
+		{"proxy error page", `<html>Bad Gateway</html>`, http.StatusBadGateway, 0, http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		err := checkResponse("getblock", []byte(test.body), test.status)
+
+		switch e := err.(type) {
+		case nil:
+			if test.code != 0 || test.http != 0 {
+				t.Errorf("%s: expected an error", test.name)
+			}
+		case *Error:
+			if e.Code != test.code || e.Method != "getblock" {
+				t.Errorf("%s: unexpected rpc error %v", test.name, e)
+			}
+		case *TransportError:
+			if e.Status != test.http || e.Method != "getblock" {
+				t.Errorf("%s: unexpected transport error %v", test.name, e)
+			}
+		default:
+			t.Errorf("%s: unexpected error type %T", test.name, err)
+		}
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"result": null, "error": {"code": -5, "message": "Block not found"}, "id": "forklol"}`))
+	}))
+	defer node.Close()
+
+	if _, err := batchClient(t, node.URL).GetBlock(context.Background(), "00"); !IsBlockNotFound(err) {
+		t.Errorf("expected a block not found error, got %v", err)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	if _, err := batchClient(t, down.URL).GetBlock(context.Background(), "00"); !IsTransportError(err) {
+		t.Errorf("expected a transport error, got %v", err)
+	}
+}
+
+// warmupNode answers -28 to the first warmups requests and with the result of getblockcount after that, other
+// methods are not found
+func warmupNode(warmups int32, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c struct {
+			Method string `json:"method"`
+		}
+		decodeBody(r, &c)
+
+		if atomic.AddInt32(requests, 1) <= warmups {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"result": null, "error": {"code": -28, "message": "Loading block index..."}, "id": "forklol"}`))
+			return
+		}
+
+		if c.Method != "getblockcount" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"result": null, "error": {"code": -32601, "message": "Method not found"}, "id": "forklol"}`))
+			return
+		}
+
+		w.Write([]byte(`{"result": 500000, "error": null, "id": "forklol"}`))
+	}))
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		warmups  int32
+		retries  int
+		requests int32
+		ok       bool
+	}{
+		{"warming up", "getblockcount", 2, 3, 3, true},
+		{"still warming up", "getblockcount", 5, 2, 3, false},
+		{"no retries", "getblockcount", 1, 0, 1, false},
+		{"not transient", "getblockcounts", 0, 3, 1, false},
+	}
+
+	for _, test := range tests {
+		var requests int32
+		node := warmupNode(test.warmups, &requests)
+
+		c, err := NewClient([]Endpoint{{URL: node.URL}}, Options{Timeout: 5 * time.Second, Retries: test.retries, RetryDelay: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Call(context.Background(), test.method, []string{})
+		node.Close()
+
+		if test.ok && err != nil {
+			t.Errorf("%s: %s", test.name, err.Error())
+		} else if !test.ok && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		if requests := atomic.LoadInt32(&requests); requests != test.requests {
+			t.Errorf("%s: %d requests, expected %d", test.name, requests, test.requests)
+		}
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	var requests int32
+	node := warmupNode(100, &requests)
+	defer node.Close()
+
+	c, err := NewClient([]Endpoint{{URL: node.URL}}, Options{Timeout: 5 * time.Second, Retries: 5, RetryDelay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Call(ctx, "getblockcount", []string{}); !IsWarmingUp(err) {
+		t.Errorf("expected the warmup error when the context is done during the backoff, got %v", err)
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("%d requests, expected 1", requests)
+	}
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,382 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is a bitcoind rpc interface. A Client prefers the endpoints with the lowest Priority. When CookieFile is
+// set, the credentials are read from that file (bitcoind's .cookie) instead of User and Pass.
+type Endpoint struct {
+	URL        string
+	User       string
+	Pass       string
+	CookieFile string
+	Priority   int
+	TLS        *TLSOptions
+}
+
+// TLSOptions configure the tls connection to an endpoint, all files are pem encoded
+type TLSOptions struct {
+	// CAFile holds the certificates used to verify the server, the system roots are used when empty
+	CAFile string
+	// CertFile and KeyFile hold a client certificate
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the host name that is verified
+	ServerName string
+}
+
+// endpoint is an Endpoint with its health as seen by the Client
+type endpoint struct {
+	Endpoint
+
+	client *http.Client
+
+	down    bool
+	lagging bool
+	height  uint64
+
+	// credentials read from the cookie file
+	cookie   string
+	cookieMu sync.Mutex
+}
+
+// SetEndpoints replaces the endpoints used for subsequent calls. It fails when the tls files of an endpoint can not
+// be loaded, in which case the current endpoints are kept.
+func (c *Client) SetEndpoints(endpoints []Endpoint) error {
+	list := make([]*endpoint, len(endpoints))
+	for n, ep := range endpoints {
+		list[n] = &endpoint{
+			Endpoint: ep,
+			client:   httpClient,
+		}
+
+		if ep.TLS != nil {
+			transport, err := newTransport(ep.TLS)
+			if err != nil {
+				return fmt.Errorf("rpc endpoint %s: %s", ep.URL, err.Error())
+			}
+			list[n].client = &http.Client{Transport: transport}
+		}
+	}
+
+	c.mu.Lock()
+	old := c.endpoints
+	c.endpoints = list
+	c.mu.Unlock()
+
+	for _, ep := range old {
+		if ep.client != httpClient {
+			ep.client.Transport.(*http.Transport).CloseIdleConnections()
+		}
+	}
+
+	return nil
+}
+
+// plainTransport returns a transport without any tls options
+func plainTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// newTransport returns a transport with the given tls options
+func newTransport(opts *TLSOptions) (*http.Transport, error) {
+	transport := plainTransport()
+
+	cfg := &tls.Config{
+		ServerName: opts.ServerName,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = cfg
+
+	return transport, nil
+}
+
+// post sends a json request body to the endpoint and returns the response body and http status code. With cookie
+// authentication the cookie file is read again and the request repeated when bitcoind answers 401, as the cookie
+// changes every time bitcoind restarts.
+func (ep *endpoint) post(ctx context.Context, j []byte) ([]byte, int, error) {
+	body, status, err := ep.send(ctx, j, false)
+	if err == nil && status == http.StatusUnauthorized && ep.CookieFile != "" {
+		body, status, err = ep.send(ctx, j, true)
+	}
+
+	return body, status, err
+}
+
+func (ep *endpoint) send(ctx context.Context, j []byte, rereadCookie bool) ([]byte, int, error) {
+	user, pass, err := ep.credentials(rereadCookie)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequest("POST", ep.URL, bytes.NewBuffer(j))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.SetBasicAuth(user, pass)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ep.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// credentials returns the user and password for the endpoint, reading the cookie file when needed
+func (ep *endpoint) credentials(reread bool) (string, string, error) {
+	if ep.CookieFile == "" {
+		return ep.User, ep.Pass, nil
+	}
+
+	ep.cookieMu.Lock()
+	defer ep.cookieMu.Unlock()
+
+	if ep.cookie == "" || reread {
+		data, err := ioutil.ReadFile(ep.CookieFile)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read cookie file: %s", err.Error())
+		}
+		ep.cookie = strings.TrimSpace(string(data))
+	}
+
+	parts := strings.SplitN(ep.cookie, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid cookie file %s", ep.CookieFile)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// preferred returns the endpoints in the order they should be tried: endpoints that are up before lagging ones,
+// down ones last, each group by priority
+func (c *Client) preferred() []*endpoint {
+	c.mu.RLock()
+	list := make([]*endpoint, len(c.endpoints))
+	copy(list, c.endpoints)
+	rank := make(map[*endpoint]int, len(list))
+	for _, ep := range list {
+		switch {
+		case ep.down:
+			rank[ep] = 2
+		case ep.lagging:
+			rank[ep] = 1
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if rank[list[i]] != rank[list[j]] {
+			return rank[list[i]] < rank[list[j]]
+		}
+		return list[i].Priority < list[j].Priority
+	})
+
+	return list
+}
+
+// do sends a request to the endpoints in order of preference until one of them gives an answer that check accepts
+// or fails with an error that is not transient. Endpoints that fail with a transient error are marked down. It
+// returns the url of the endpoint that served the request.
+func (c *Client) do(ctx context.Context, method string, j []byte, check func(body []byte, status int) error) (string, error) {
+	c.mu.RLock()
+	timeout := c.opts.Timeout
+	c.mu.RUnlock()
+
+	err := errors.New("no rpc endpoints configured")
+
+	for _, ep := range c.preferred() {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		body, status, postErr := ep.post(callCtx, j)
+		cancel()
+
+		if postErr != nil {
+			err = &TransportError{Method: method, Err: postErr}
+		} else {
+			err = check(body, status)
+		}
+
+		if err == nil || !isTransient(err) {
+			c.setDown(ep, false, nil)
+			return ep.URL, err
+		}
+
+		c.setDown(ep, true, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return "", err
+}
+
+// setDown changes the state of an endpoint and logs when it went down or came back up
+func (c *Client) setDown(ep *endpoint, down bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ep.down == down {
+		return
+	}
+	ep.down = down
+
+	if down && len(c.endpoints) > 1 {
+		log.Printf("rpc endpoint %s is down, failing over: %s\n", ep.URL, err.Error())
+	} else if !down {
+		log.Printf("rpc endpoint %s is back up\n", ep.URL)
+	}
+}
+
+// HealthCheck asks every endpoint for its height. Endpoints that do not answer are marked down, endpoints that are
+// more than MaxLag blocks behind the best one are marked lagging. It fails when all endpoints are down.
+func (c *Client) HealthCheck(ctx context.Context) error {
+	c.mu.RLock()
+	endpoints := c.endpoints
+	maxLag := c.opts.MaxLag
+	c.mu.RUnlock()
+
+	// a single endpoint is used no matter what, failing calls will tell when it is down
+	if len(endpoints) < 2 {
+		return nil
+	}
+
+	heights := make([]uint64, len(endpoints))
+	errs := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+	for n, ep := range endpoints {
+		wg.Add(1)
+		go func(n int, ep *endpoint) {
+			defer wg.Done()
+			heights[n], errs[n] = c.endpointHeight(ctx, ep)
+		}(n, ep)
+	}
+	wg.Wait()
+
+	best := uint64(0)
+	for n := range endpoints {
+		if errs[n] == nil && heights[n] > best {
+			best = heights[n]
+		}
+	}
+
+	var err error
+	up := 0
+
+	for n, ep := range endpoints {
+		if errs[n] != nil {
+			c.setDown(ep, true, errs[n])
+			err = errs[n]
+			continue
+		}
+
+		up++
+		c.setDown(ep, false, nil)
+
+		c.mu.Lock()
+		ep.height = heights[n]
+		lagging := best-heights[n] > maxLag
+		if lagging != ep.lagging {
+			if lagging {
+				log.Printf("rpc endpoint %s is lagging behind at height %d (best %d)\n", ep.URL, heights[n], best)
+			} else {
+				log.Printf("rpc endpoint %s caught up at height %d\n", ep.URL, heights[n])
+			}
+		}
+		ep.lagging = lagging
+		c.mu.Unlock()
+	}
+
+	if up == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// endpointHeight returns the height of the best block of a single endpoint
+func (c *Client) endpointHeight(ctx context.Context, ep *endpoint) (uint64, error) {
+	j, err := json.Marshal(call{
+		JsonRPC: "1.0",
+		Id:      "forklol",
+		Method:  "getblockchaininfo",
+		Params:  []string{},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.RLock()
+	timeout := c.opts.Timeout
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, status, err := ep.post(ctx, j)
+	if err != nil {
+		return 0, &TransportError{Method: "getblockchaininfo", Err: err}
+	}
+
+	if err := checkResponse("getblockchaininfo", body, status); err != nil {
+		return 0, err
+	}
+
+	t := struct {
+		Result struct {
+			Height uint64 `json:"blocks"`
+		} `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(body, &t); err != nil {
+		return 0, err
+	}
+
+	return t.Result.Height, nil
+}
@@ -0,0 +1,201 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// heightNode answers getblockchaininfo with the given height and every other call with its own name
+func heightNode(name string, height uint64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var c struct {
+			Method string `json:"method"`
+		}
+		decodeBody(r, &c)
+
+		if c.Method == "getblockchaininfo" {
+			fmt.Fprintf(w, `{"result": {"blocks": %d}, "error": null, "id": "forklol"}`, height)
+			return
+		}
+
+		fmt.Fprintf(w, `{"result": "%s", "error": null, "id": "forklol"}`, name)
+	}))
+}
+
+func endpointsClient(t *testing.T, endpoints []Endpoint) *Client {
+	c, err := NewClient(endpoints, Options{Timeout: 5 * time.Second, Retries: 0, RetryDelay: time.Millisecond, MaxLag: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestFailover(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	backup := heightNode("backup", 100)
+	defer backup.Close()
+
+	c := endpointsClient(t, []Endpoint{
+		{URL: backup.URL, Priority: 1},
+		{URL: refused.URL, Priority: 0},
+	})
+
+	body, served, err := c.call(context.Background(), "getbestblockhash", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if served != backup.URL || string(*body) != `{"result": "backup", "error": null, "id": "forklol"}` {
+		t.Errorf("served by %s: %s, expected the priority 1 endpoint", served, *body)
+	}
+
+	// the endpoint that refused the connection is tried last from now on
+	if preferred := c.preferred(); preferred[0].URL != backup.URL || !preferred[1].down {
+		t.Errorf("the refusing endpoint is not ranked down: %s first", preferred[0].URL)
+	}
+}
+
+func TestHealthCheckLagging(t *testing.T) {
+	lagging := heightNode("lagging", 95)
+	defer lagging.Close()
+	best := heightNode("best", 100)
+	defer best.Close()
+	near := heightNode("near", 99)
+	defer near.Close()
+
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	c := endpointsClient(t, []Endpoint{
+		{URL: lagging.URL, Priority: 0},
+		{URL: refused.URL, Priority: 0},
+		{URL: best.URL, Priority: 2},
+		{URL: near.URL, Priority: 1},
+	})
+
+	if err := c.HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// up by priority, then lagging, then down
+	expected := []string{near.URL, best.URL, lagging.URL, refused.URL}
+	for n, ep := range c.preferred() {
+		if ep.URL != expected[n] {
+			t.Errorf("endpoint %d is %s, expected %s", n, ep.URL, expected[n])
+		}
+	}
+
+	_, served, err := c.call(context.Background(), "getbestblockhash", []string{})
+	if err != nil || served != near.URL {
+		t.Errorf("served by %s (%v), expected the endpoint within the lag", served, err)
+	}
+}
+
+func TestHealthCheckAllDown(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	c := endpointsClient(t, []Endpoint{{URL: refused.URL}, {URL: refused.URL + "/wallet"}})
+
+	if err := c.HealthCheck(context.Background()); !IsTransportError(err) {
+		t.Errorf("expected a transport error when all endpoints are down, got %v", err)
+	}
+}
+
+// cookieNode accepts the credentials in the cookie it is given and counts the requests it gets
+func cookieNode(cookie *atomic.Value, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		user, pass, _ := r.BasicAuth()
+		if user+":"+pass != cookie.Load().(string) {
+			// bitcoind answers bad credentials without a body
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"result": 500000, "error": null, "id": "forklol"}`))
+	}))
+}
+
+func TestCookieReread(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forklol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, ".cookie")
+	if err := ioutil.WriteFile(path, []byte("__cookie__:first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cookie atomic.Value
+	cookie.Store("__cookie__:first")
+	var requests int32
+
+	node := cookieNode(&cookie, &requests)
+	defer node.Close()
+
+	c := endpointsClient(t, []Endpoint{{URL: node.URL, CookieFile: path}})
+
+	if _, err := c.Call(context.Background(), "getblockcount", []string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// bitcoind restarted with a new cookie, the cached one is refused once and the file read again
+	cookie.Store("__cookie__:second")
+	if err := ioutil.WriteFile(path, []byte("__cookie__:second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Call(context.Background(), "getblockcount", []string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("%d requests, expected 3", n)
+	}
+
+	// a cookie that is still refused after reading it again is not retried any further
+	cookie.Store("__cookie__:third")
+
+	_, err = c.Call(context.Background(), "getblockcount", []string{})
+	if e, ok := err.(*TransportError); !ok || e.Status != http.StatusUnauthorized {
+		t.Errorf("expected an unauthorized transport error, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 5 {
+		t.Errorf("%d requests, expected 5", n)
+	}
+}
+
+func TestCookieMissing(t *testing.T) {
+	var cookie atomic.Value
+	cookie.Store("__cookie__:first")
+	var requests int32
+
+	node := cookieNode(&cookie, &requests)
+	defer node.Close()
+
+	c := endpointsClient(t, []Endpoint{{URL: node.URL, CookieFile: "/nonexistent/.cookie"}})
+
+	if _, err := c.Call(context.Background(), "getblockcount", []string{}); !IsTransportError(err) {
+		t.Errorf("expected a transport error without a cookie file, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("%d requests without credentials, expected none", n)
+	}
+}
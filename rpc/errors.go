@@ -0,0 +1,74 @@
+package rpc
+
+import "fmt"
+
+// Error codes returned by bitcoind
+const (
+	ErrCodeMisc             = -1
+	ErrCodeType             = -3
+	ErrCodeBlockNotFound    = -5
+	ErrCodeInvalidParameter = -8
+	ErrCodeWarmup           = -28
+	ErrCodeMethodNotFound   = -32601
+)
+
+// Error is an error returned by bitcoind in the error field of a json-rpc response
+type Error struct {
+	Method  string `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: rpc error %d: %s", e.Method, e.Code, e.Message)
+}
+
+// TransportError is returned when bitcoind could not be reached or did not answer with a json-rpc response
+type TransportError struct {
+	Method string
+	Status int
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s: http status %d: %s", e.Method, e.Status, e.Err.Error())
+	}
+	return fmt.Sprintf("%s: %s", e.Method, e.Err.Error())
+}
+
+// IsTransportError returns true when err is a *TransportError
+func IsTransportError(err error) bool {
+	_, ok := err.(*TransportError)
+	return ok
+}
+
+// IsBlockNotFound returns true when bitcoind does not know the requested block
+func IsBlockNotFound(err error) bool {
+	return hasCode(err, ErrCodeBlockNotFound)
+}
+
+// IsInvalidParameter returns true when the node rejected a parameter of the call
+func IsInvalidParameter(err error) bool {
+	return hasCode(err, ErrCodeInvalidParameter)
+}
+
+// IsHeightOutOfRange returns true when a height above the node's tip was requested
+func IsHeightOutOfRange(err error) bool {
+	return hasCode(err, ErrCodeInvalidParameter)
+}
+
+// IsWarmingUp returns true when bitcoind is still starting up (loading the block index, verifying blocks, ...)
+func IsWarmingUp(err error) bool {
+	return hasCode(err, ErrCodeWarmup)
+}
+
+// IsMethodNotFound returns true when bitcoind does not implement the called method
+func IsMethodNotFound(err error) bool {
+	return hasCode(err, ErrCodeMethodNotFound)
+}
+
+func hasCode(err error, code int) bool {
+	e, ok := err.(*Error)
+	return ok && e.Code == code
+}
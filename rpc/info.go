@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// NetworkInfo is used by rpc.GetNetworkInfo()
+type NetworkInfo struct {
+	Version    int    `json:"version"`
+	SubVersion string `json:"subversion"`
+}
+
+// GetNetworkInfo returns the version and user agent of the node
+func (c *Client) GetNetworkInfo(ctx context.Context) (*NetworkInfo, error) {
+	j, err := c.Call(ctx, "getnetworkinfo", []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	t := struct {
+		Result NetworkInfo `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(*j, &t); err != nil {
+		return nil, err
+	}
+
+	return &t.Result, nil
+}
+
+// Deployment is a soft fork as reported by getblockchaininfo (Core 0.19 - 22) or getdeploymentinfo (Core 23+)
+type Deployment struct {
+	Type   string `json:"type"`
+	Active bool   `json:"active"`
+	Height int64  `json:"height"`
+}
+
+// BIP9Deployment is a soft fork as reported in bip9_softforks by getblockchaininfo (Core 0.14 - 0.18)
+type BIP9Deployment struct {
+	Status string `json:"status"`
+	Since  int64  `json:"since"`
+}
+
+// BlockchainInfo is used by rpc.GetBlockchainInfo(). Depending on the node version soft forks are found in
+// SoftForks (a map from Core 0.19, a list before) or BIP9SoftForks.
+type BlockchainInfo struct {
+	Chain         string                    `json:"chain"`
+	Height        uint64                    `json:"blocks"`
+	BlockHash     string                    `json:"bestblockhash"`
+	SoftForks     json.RawMessage           `json:"softforks"`
+	BIP9SoftForks map[string]BIP9Deployment `json:"bip9_softforks"`
+}
+
+// GetBlockchainInfo returns information about the chain of the node, including its soft forks
+func (c *Client) GetBlockchainInfo(ctx context.Context) (*BlockchainInfo, error) {
+	j, err := c.Call(ctx, "getblockchaininfo", []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	t := struct {
+		Result BlockchainInfo `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(*j, &t); err != nil {
+		return nil, err
+	}
+
+	return &t.Result, nil
+}
+
+// GetDeploymentInfo returns the soft fork deployments of the node (Core 23+)
+func (c *Client) GetDeploymentInfo(ctx context.Context) (map[string]Deployment, error) {
+	j, err := c.Call(ctx, "getdeploymentinfo", []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	t := struct {
+		Result struct {
+			Deployments map[string]Deployment `json:"deployments"`
+		} `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(*j, &t); err != nil {
+		return nil, err
+	}
+
+	return t.Result.Deployments, nil
+}
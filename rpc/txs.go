@@ -0,0 +1,50 @@
+package rpc
+
+import "encoding/json"
+
+// TxOut is an output of a transaction. Value is nil for confidential (Elements) outputs.
+type TxOut struct {
+	Value        *float64 `json:"value"`
+	ScriptPubKey struct {
+		Hex  string `json:"hex"`
+		Type string `json:"type"`
+	} `json:"scriptPubKey"`
+}
+
+// TxIn is an input of a transaction. Prevout is only returned by nodes that have the undo data of the block
+// (getblock verbosity 3).
+type TxIn struct {
+	Coinbase string `json:"coinbase"`
+	Prevout  *TxOut `json:"prevout"`
+}
+
+// Tx is a transaction of a VerboseBlock. Fee (in BTC) is only returned by Core 0.21+ and is nil otherwise. At
+// verbosity 1 only Txid is set.
+type Tx struct {
+	Txid   string   `json:"txid"`
+	Hash   string   `json:"hash"`
+	Size   uint64   `json:"size"`
+	VSize  uint64   `json:"vsize"`
+	Weight uint64   `json:"weight"`
+	Fee    *float64 `json:"fee"`
+	Vin    []TxIn   `json:"vin"`
+	Vout   []TxOut  `json:"vout"`
+}
+
+// UnmarshalJSON decodes a transaction, or just its txid at verbosity 1
+func (t *Tx) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*t = Tx{}
+		return json.Unmarshal(data, &t.Txid)
+	}
+
+	// an alias without this method decodes the fields
+	type tx Tx
+	return json.Unmarshal(data, (*tx)(t))
+}
+
+// VerboseBlock is a block including its decoded transactions
+type VerboseBlock struct {
+	Block
+	Tx []Tx `json:"tx"`
+}
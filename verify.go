@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"forklol-collector/bitcoin"
+	"forklol-collector/config"
+	"forklol-collector/db"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runVerify implements the verify subcommand: verify [-repair] SYMBOL [FROM [TO]]. It returns the exit code.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "re-sync the bad ranges that were found")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] verify [-repair] SYMBOL [FROM [TO]]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 3 {
+		fs.Usage()
+		return 2
+	}
+
+	coin, ok := findCoin(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Coin %s is not configured.\n", fs.Arg(0))
+		return 2
+	}
+
+	from, to, code := heightRange(coin.Symbol, fs.Args()[1:])
+	if code != 0 {
+		return code
+	}
+
+	// blocks seeded before the start height have no details or hashrates
+	if fs.NArg() < 2 && from < *coin.StartHeight {
+		from = *coin.StartHeight
+	}
+
+	c, err := bitcoin.NewCoin(coin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not configure coin %s\n", err.Error())
+		return 2
+	}
+
+	sync := bitcoin.NewChainSync(c)
+
+	report, err := sync.Verify(context.Background(), from, to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not verify %s: %s\n", coin.Symbol, err.Error())
+		return 1
+	}
+
+	report.Print(os.Stdout)
+
+	if report.OK() {
+		return 0
+	}
+
+	if *repair {
+		if err := sync.Repair(context.Background(), report); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not repair %s: %s\n", coin.Symbol, err.Error())
+			return 1
+		}
+
+		return 0
+	}
+
+	return 1
+}
+
+// heightRange returns the optional FROM and TO heights of args, defaulting to the stored heights of the coin. A
+// non-zero exit code is returned when they can not be determined.
+func heightRange(symbol string, args []string) (uint64, uint64, int) {
+	from, to, err := db.GetHeightRange(symbol)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not get stored %s heights: %s\n", symbol, err.Error())
+		return 0, 0, 1
+	}
+
+	for n, h := range []*uint64{&from, &to} {
+		if len(args) < n+1 {
+			break
+		}
+
+		if *h, err = strconv.ParseUint(args[n], 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid height %s.\n", args[n])
+			return 0, 0, 2
+		}
+	}
+
+	return from, to, 0
+}
+
+// findCoin returns the options of a configured coin by symbol
+func findCoin(symbol string) (config.CoinOptions, bool) {
+	for _, coin := range config.Options().Coins() {
+		if coin.Symbol == strings.ToUpper(symbol) {
+			return coin, true
+		}
+	}
+
+	return config.CoinOptions{}, false
+}
@@ -0,0 +1,179 @@
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Publisher is a minimal PUB socket that stands in for bitcoind's zmq interface (-zmqpubhashblock) in tests
+type Publisher struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	subs map[*pubConn]bool
+}
+
+type pubConn struct {
+	conn net.Conn
+
+	mu     sync.Mutex
+	topics [][]byte
+}
+
+// Listen starts a publisher at endpoint (tcp://host:port, port 0 picks a free port)
+func Listen(endpoint string) (*Publisher, error) {
+	addr, err := address(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Publisher{
+		ln:   ln,
+		subs: map[*pubConn]bool{},
+	}
+
+	go p.accept()
+
+	return p, nil
+}
+
+// Endpoint returns the tcp:// endpoint subscribers can connect to
+func (p *Publisher) Endpoint() string {
+	return "tcp://" + p.ln.Addr().String()
+}
+
+// Publish sends a message to all subscribers of its first frame (the topic)
+func (p *Publisher) Publish(frames ...[]byte) {
+	p.mu.Lock()
+	subs := make([]*pubConn, 0, len(p.subs))
+	for sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	p.mu.Unlock()
+
+	for _, sub := range subs {
+		if !sub.subscribed(frames[0]) {
+			continue
+		}
+
+		if err := writeMessage(sub.conn, frames); err != nil {
+			p.drop(sub)
+		}
+	}
+}
+
+// Close stops the publisher and disconnects all subscribers
+func (p *Publisher) Close() error {
+	err := p.ln.Close()
+
+	p.mu.Lock()
+	for sub := range p.subs {
+		sub.conn.Close()
+	}
+	p.subs = map[*pubConn]bool{}
+	p.mu.Unlock()
+
+	return err
+}
+
+// waitSubscribed blocks until a subscriber receives topic, subscriptions arrive asynchronously after the handshake
+func (p *Publisher) waitSubscribed(topic []byte, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		p.mu.Lock()
+		for sub := range p.subs {
+			if sub.subscribed(topic) {
+				p.mu.Unlock()
+				return true
+			}
+		}
+		p.mu.Unlock()
+	}
+
+	return false
+}
+
+func (p *Publisher) accept() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go p.serve(conn)
+	}
+}
+
+// serve does the handshake with a subscriber and reads its subscriptions until it disconnects
+func (p *Publisher) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if err := handshake(conn, r, "PUB"); err != nil {
+		conn.Close()
+		return
+	}
+
+	sub := &pubConn{conn: conn}
+
+	p.mu.Lock()
+	p.subs[sub] = true
+	p.mu.Unlock()
+
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			p.drop(sub)
+			return
+		}
+
+		if len(msg) == 1 && len(msg[0]) > 0 && msg[0][0] == 1 {
+			sub.mu.Lock()
+			sub.topics = append(sub.topics, msg[0][1:])
+			sub.mu.Unlock()
+		}
+	}
+}
+
+func (p *Publisher) drop(sub *pubConn) {
+	p.mu.Lock()
+	delete(p.subs, sub)
+	p.mu.Unlock()
+
+	sub.conn.Close()
+}
+
+func (c *pubConn) subscribed(topic []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.topics {
+		if bytes.HasPrefix(topic, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeMessage writes all frames of a message
+func writeMessage(w io.Writer, frames [][]byte) error {
+	for n, frame := range frames {
+		flags := byte(0)
+		if n < len(frames)-1 {
+			flags = flagMore
+		}
+
+		if err := writeFrame(w, frame, flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package zmq
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"time"
+)
+
+// Subscriber is a SUB socket connected to a single publisher
+type Subscriber struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Subscribe connects to a publisher at endpoint (tcp://host:port) and subscribes to the given topics
+func Subscribe(ctx context.Context, endpoint string, topics ...string) (*Subscriber, error) {
+	addr, err := address(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscriber{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := handshake(conn, s.r, "SUB"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// ZMTP 3.0 subscriptions are messages that start with 0x01
+	for _, topic := range topics {
+		if err := writeFrame(conn, append([]byte{1}, topic...), 0); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return s, nil
+}
+
+// Receive waits for the next message and returns its frames. A bitcoind notification has three frames: the topic,
+// the body and a sequence number.
+func (s *Subscriber) Receive() ([][]byte, error) {
+	return readMessage(s.r)
+}
+
+// Close closes the connection to the publisher
+func (s *Subscriber) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,157 @@
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   []byte
+		flags  byte
+		header []byte
+	}{
+		{"empty", []byte{}, 0, []byte{0, 0}},
+		{"short", []byte("hashblock"), flagMore, []byte{flagMore, 9}},
+		{"255 bytes", bytes.Repeat([]byte{1}, 255), 0, []byte{0, 255}},
+		{"long", bytes.Repeat([]byte{1}, 256), 0, []byte{flagLong, 0, 0, 0, 0, 0, 0, 1, 0}},
+		{"command", []byte("\x05READY"), flagCommand, []byte{flagCommand, 6}},
+	}
+
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := writeFrame(&b, test.body, test.flags); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if header := b.Bytes()[:len(test.header)]; !bytes.Equal(header, test.header) {
+			t.Errorf("%s: header is %v, expected %v", test.name, header, test.header)
+		}
+
+		body, flags, err := readFrame(bufio.NewReader(&b))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if !bytes.Equal(body, test.body) {
+			t.Errorf("%s: body of %d bytes read back as %d bytes", test.name, len(test.body), len(body))
+		}
+
+		if expected := test.header[0]; flags != expected {
+			t.Errorf("%s: flags are %d, expected %d", test.name, flags, expected)
+		}
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	header := []byte{flagLong, 0, 0, 0, 0, 0x10, 0, 0, 0}
+
+	if _, _, err := readFrame(bufio.NewReader(bytes.NewReader(header))); err == nil {
+		t.Error("expected a frame of 256MB to be refused")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	var b bytes.Buffer
+	writeFrame(&b, []byte("\x04PING"), flagCommand)
+	writeMessage(&b, [][]byte{[]byte("hashblock"), {0xab, 0xcd}, {1, 0, 0, 0}})
+	writeMessage(&b, [][]byte{[]byte("single")})
+
+	r := bufio.NewReader(&b)
+
+	tests := [][][]byte{
+		{[]byte("hashblock"), {0xab, 0xcd}, {1, 0, 0, 0}},
+		{[]byte("single")},
+	}
+
+	for n, expected := range tests {
+		msg, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("message %d: %s", n, err)
+		}
+
+		if !reflect.DeepEqual(msg, expected) {
+			t.Errorf("message %d is %q, expected %q", n, msg, expected)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		addr     string
+		ok       bool
+	}{
+		{"tcp://127.0.0.1:28332", "127.0.0.1:28332", true},
+		{"tcp://node:28332", "node:28332", true},
+		{"ipc:///tmp/bitcoind", "", false},
+		{"127.0.0.1:28332", "", false},
+	}
+
+	for _, test := range tests {
+		addr, err := address(test.endpoint)
+		if (err == nil) != test.ok || addr != test.addr {
+			t.Errorf("address(%s) is %s, %v", test.endpoint, addr, err)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	pub, err := Listen("tcp://127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := Subscribe(ctx, pub.Endpoint(), "hashblock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if !pub.waitSubscribed([]byte("hashblock"), 5*time.Second) {
+		t.Fatal("publisher never received the subscription")
+	}
+
+	hash := bytes.Repeat([]byte{0x42}, 32)
+	expected := [][]byte{[]byte("hashblock"), hash, {7, 0, 0, 0}}
+
+	// hashtx is not subscribed to and has to be filtered by the publisher
+	pub.Publish([]byte("hashtx"), bytes.Repeat([]byte{0x01}, 32), []byte{0, 0, 0, 0})
+	pub.Publish(expected...)
+
+	msgs := make(chan [][]byte, 1)
+	go func() {
+		msg, err := sub.Receive()
+		if err != nil {
+			t.Error(err)
+		}
+		msgs <- msg
+	}()
+
+	select {
+	case msg := <-msgs:
+		if !reflect.DeepEqual(msg, expected) {
+			t.Errorf("received %q, expected %q", msg, expected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := Subscribe(ctx, "ipc:///tmp/bitcoind"); err == nil {
+		t.Error("expected an ipc endpoint to be refused")
+	}
+}
@@ -0,0 +1,176 @@
+// Package zmq implements just enough of ZMTP 3.0 (the zeromq wire protocol) to subscribe to bitcoind's zmq
+// notifications.
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	// frames larger than this are refused, bitcoind never sends more than a raw block
+	maxFrameSize = 64 * 1024 * 1024
+)
+
+// greeting returns the 64 byte ZMTP 3.0 greeting with the NULL security mechanism
+func greeting() []byte {
+	g := make([]byte, 64)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3 // major version
+	g[11] = 0 // minor version
+	copy(g[12:32], "NULL")
+	return g
+}
+
+// handshake exchanges greetings and READY commands with the peer
+func handshake(conn net.Conn, r *bufio.Reader, socketType string) error {
+	if _, err := conn.Write(greeting()); err != nil {
+		return err
+	}
+
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return err
+	}
+
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return errors.New("zmq: peer did not send a ZMTP greeting")
+	}
+
+	if peer[10] < 3 {
+		return fmt.Errorf("zmq: unsupported ZMTP version %d.%d", peer[10], peer[11])
+	}
+
+	if mech := string(bytes.TrimRight(peer[12:32], "\x00")); mech != "NULL" {
+		return fmt.Errorf("zmq: unsupported security mechanism %s", mech)
+	}
+
+	if err := writeFrame(conn, readyCommand(socketType), flagCommand); err != nil {
+		return err
+	}
+
+	body, flags, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+
+	if flags&flagCommand == 0 || !bytes.HasPrefix(body, []byte("\x05READY")) {
+		return errors.New("zmq: peer did not send READY")
+	}
+
+	return nil
+}
+
+// readyCommand returns the body of a READY command with the Socket-Type property
+func readyCommand(socketType string) []byte {
+	var b bytes.Buffer
+
+	b.WriteByte(5)
+	b.WriteString("READY")
+
+	name := "Socket-Type"
+	b.WriteByte(byte(len(name)))
+	b.WriteString(name)
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(socketType)))
+	b.Write(size)
+	b.WriteString(socketType)
+
+	return b.Bytes()
+}
+
+// writeFrame writes a single frame with the given flags, the size flag is added when needed
+func writeFrame(w io.Writer, body []byte, flags byte) error {
+	var header []byte
+
+	if len(body) > 255 {
+		header = make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+	} else {
+		header = []byte{flags, byte(len(body))}
+	}
+
+	if _, err := w.Write(append(header, body...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// readFrame reads a single frame and returns its body and flags
+func readFrame(r *bufio.Reader) ([]byte, byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var size uint64
+	if flags&flagLong != 0 {
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, 0, err
+		}
+		size = binary.BigEndian.Uint64(b)
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, 0, err
+		}
+		size = uint64(b)
+	}
+
+	if size > maxFrameSize {
+		return nil, 0, fmt.Errorf("zmq: frame of %d bytes is too large", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, err
+	}
+
+	return body, flags, nil
+}
+
+// readMessage reads all frames of the next message, commands are skipped
+func readMessage(r *bufio.Reader) ([][]byte, error) {
+	frames := make([][]byte, 0, 3)
+
+	for {
+		body, flags, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if flags&flagCommand != 0 {
+			continue
+		}
+
+		frames = append(frames, body)
+
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// address turns a zmq endpoint like tcp://127.0.0.1:28332 into a host:port
+func address(endpoint string) (string, error) {
+	if !strings.HasPrefix(endpoint, "tcp://") {
+		return "", fmt.Errorf("zmq: only tcp:// endpoints are supported, got %s", endpoint)
+	}
+
+	return strings.TrimPrefix(endpoint, "tcp://"), nil
+}